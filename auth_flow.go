@@ -2,6 +2,7 @@ package tinkoff
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tebeka/selenium"
@@ -32,6 +33,8 @@ var ApiAuthFlow AuthFlow = &apiAuthFlow{}
 
 type apiAuthFlow struct{}
 
+func (f *apiAuthFlow) String() string { return "api" }
+
 func (f *apiAuthFlow) authorize(ctx context.Context, c *Client, authorizer Authorizer) (*Session, error) {
 	var session *Session
 	if resp, err := executeCommon(ctx, c, sessionIn{}); err != nil {
@@ -41,10 +44,10 @@ func (f *apiAuthFlow) authorize(ctx context.Context, c *Client, authorizer Autho
 		ctx = withSession(ctx, session)
 	}
 
-	if resp, err := executeCommon(ctx, c, phoneSignUpIn{Phone: c.credential.Phone}); err != nil {
+	if resp, err := executeCommon(ctx, c, phoneSignUpIn{Phone: c.phone}); err != nil {
 		return nil, errors.Wrap(err, "phone sign up")
 	} else {
-		code, err := authorizer.GetConfirmationCode(ctx, c.credential.Phone)
+		code, err := authorizer.GetConfirmationCode(ctx, c.phone)
 		if err != nil {
 			return nil, errors.Wrap(err, "get confirmation code")
 		}
@@ -56,9 +59,16 @@ func (f *apiAuthFlow) authorize(ctx context.Context, c *Client, authorizer Autho
 		}); err != nil {
 			return nil, errors.Wrap(err, "submit confirmation code")
 		}
+
+		c.observe(ctx, AuthCodeSubmitted, "", nil)
+	}
+
+	password, err := c.password(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := executeCommon(ctx, c, passwordSignUpIn{Password: c.credential.Password}); err != nil {
+	if _, err := executeCommon(ctx, c, passwordSignUpIn{Password: password}); err != nil {
 		return nil, errors.Wrap(err, "password sign up")
 	}
 
@@ -74,9 +84,19 @@ func (f *apiAuthFlow) authorize(ctx context.Context, c *Client, authorizer Autho
 type SeleniumAuthFlow struct {
 	Capabilities selenium.Capabilities
 	URLPrefix    string
+
+	// Steps – шаги авторизации на странице входа. По умолчанию используется DefaultLoginSteps.
+	// Таблица проверяется до запуска браузера: нужен хотя бы один терминальный шаг и только известные действия.
+	Steps []LoginStep
 }
 
+func (f *SeleniumAuthFlow) String() string { return "selenium" }
+
 func (f *SeleniumAuthFlow) authorize(ctx context.Context, c *Client, authorizer Authorizer) (*Session, error) {
+	if err := validateLoginSteps(f.Steps); err != nil {
+		return nil, err
+	}
+
 	driver, err := selenium.NewRemote(f.Capabilities, f.URLPrefix)
 	if err != nil {
 		return nil, errors.Wrap(err, "create remote")
@@ -88,61 +108,12 @@ func (f *SeleniumAuthFlow) authorize(ctx context.Context, c *Client, authorizer
 		return nil, errors.Wrap(err, "maximize window")
 	}
 
-	if err := driver.Get(baseURL + "/login"); err != nil {
+	if err := driver.Get(c.baseURL + "/login"); err != nil {
 		return nil, errors.Wrap(err, "open login page")
 	}
 
-	var complete bool
-	steps := map[string]func(el selenium.WebElement) error{
-		"//input[@automation-id='phone-input']":    func(el selenium.WebElement) error { return el.SendKeys(c.credential.Phone + selenium.EnterKey) },
-		"//input[@automation-id='password-input']": func(el selenium.WebElement) error { return el.SendKeys(c.credential.Password + selenium.EnterKey) },
-		"//input[@automation-id='otp-input']": func(el selenium.WebElement) error {
-			code, err := authorizer.GetConfirmationCode(ctx, c.credential.Phone)
-			if err != nil {
-				return errors.Wrap(err, "get confirmation code")
-			}
-
-			return el.SendKeys(code)
-		},
-		"//button[@automation-id='cancel-button']": func(el selenium.WebElement) error { return el.Click() },
-		"//a[@href='/new-product/']":               func(_ selenium.WebElement) error { complete = true; return nil },
-	}
-
-	for !complete {
-		if err := driver.Wait(func(wd selenium.WebDriver) (bool, error) {
-			select {
-			case <-ctx.Done():
-				return false, ctx.Err()
-			default:
-			}
-
-			for xpath, handle := range steps {
-				elements, err := driver.FindElements(selenium.ByXPATH, xpath)
-				if err != nil {
-					return false, errors.Wrapf(err, "find xpath '%s'", xpath)
-				}
-
-				for _, element := range elements {
-					displayed, err := element.IsDisplayed()
-					if err != nil {
-						return false, errors.Wrapf(err, "xpath '%s' is displayed", xpath)
-					}
-
-					if displayed {
-						if err := handle(element); err != nil {
-							return false, errors.Wrapf(err, "handle xpath '%s'", xpath)
-						}
-
-						delete(steps, xpath)
-						return true, nil
-					}
-				}
-			}
-
-			return false, nil
-		}); err != nil {
-			return nil, err
-		}
+	if err := runLogin(ctx, c, authorizer, seleniumBrowser{driver: driver}, f.Steps); err != nil {
+		return nil, err
 	}
 
 	sessionID, err := driver.GetCookie("psid")
@@ -150,7 +121,48 @@ func (f *SeleniumAuthFlow) authorize(ctx context.Context, c *Client, authorizer
 		return nil, errors.Wrap(err, "session cookie not found")
 	}
 
-	return &Session{
-		ID: sessionID.Value,
-	}, nil
+	session := &Session{ID: sessionID.Value}
+	if sessionID.Expiry > 0 {
+		session.ExpiresAt = time.Unix(int64(sessionID.Expiry), 0)
+	}
+
+	return session, nil
+}
+
+type seleniumBrowser struct {
+	driver selenium.WebDriver
+}
+
+func (b seleniumBrowser) findElements(_ context.Context, xpath string) ([]browserElement, error) {
+	elements, err := b.driver.FindElements(selenium.ByXPATH, xpath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]browserElement, len(elements))
+	for i, element := range elements {
+		result[i] = seleniumElement{element: element}
+	}
+
+	return result, nil
+}
+
+type seleniumElement struct {
+	element selenium.WebElement
+}
+
+func (el seleniumElement) isDisplayed(context.Context) (bool, error) {
+	return el.element.IsDisplayed()
+}
+
+func (el seleniumElement) sendKeys(_ context.Context, text string, enter bool) error {
+	if enter {
+		text += selenium.EnterKey
+	}
+
+	return el.element.SendKeys(text)
+}
+
+func (el seleniumElement) click(context.Context) error {
+	return el.element.Click()
 }
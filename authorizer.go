@@ -1,11 +1,37 @@
 package tinkoff
 
-import "context"
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+)
+
+const defaultFileAuthorizerPollInterval = time.Second
+
+// ErrNoPendingRequest возвращается при отправке кода, который никто не ожидает.
+var ErrNoPendingRequest = errors.New("no pending confirmation code request")
 
 type Authorizer interface {
 	GetConfirmationCode(ctx context.Context, phone string) (string, error)
 }
 
+// AuthorizerFunc – функциональный адаптер для Authorizer.
+type AuthorizerFunc func(ctx context.Context, phone string) (string, error)
+
+func (fn AuthorizerFunc) GetConfirmationCode(ctx context.Context, phone string) (string, error) {
+	return fn(ctx, phone)
+}
+
 type authorizerKey struct{}
 
 func WithAuthorizer(ctx context.Context, authorizer Authorizer) context.Context {
@@ -16,3 +42,296 @@ func getAuthorizer(ctx context.Context) Authorizer {
 	authorizer, _ := ctx.Value(authorizerKey{}).(Authorizer)
 	return authorizer
 }
+
+// ChannelAuthorizer ожидает код подтверждения, переданный через Submit.
+// Подходит для встраивания в ботов: номера телефонов, для которых запрошен код, поступают в канал Requests.
+type ChannelAuthorizer struct {
+	requests chan string
+	waiters  map[string][]chan string
+	mu       sync.Mutex
+}
+
+func NewChannelAuthorizer() *ChannelAuthorizer {
+	return &ChannelAuthorizer{
+		requests: make(chan string, 16),
+		waiters:  make(map[string][]chan string),
+	}
+}
+
+// Requests возвращает канал с номерами телефонов, для которых запрошен код подтверждения.
+// Если канал не читается, уведомления отбрасываются, а ожидание кода продолжается.
+func (a *ChannelAuthorizer) Requests() <-chan string {
+	return a.requests
+}
+
+// Pending возвращает номера телефонов, для которых ожидается код подтверждения.
+func (a *ChannelAuthorizer) Pending() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	phones := make([]string, 0, len(a.waiters))
+	for phone := range a.waiters {
+		phones = append(phones, phone)
+	}
+
+	return phones
+}
+
+// Submit передает код подтверждения всем ожидающим его для номера телефона phone.
+func (a *ChannelAuthorizer) Submit(phone, code string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	waiters := a.waiters[phone]
+	if len(waiters) == 0 {
+		return ErrNoPendingRequest
+	}
+
+	for _, waiter := range waiters {
+		waiter <- code
+	}
+
+	delete(a.waiters, phone)
+	return nil
+}
+
+func (a *ChannelAuthorizer) GetConfirmationCode(ctx context.Context, phone string) (string, error) {
+	waiter := make(chan string, 1)
+	a.mu.Lock()
+	a.waiters[phone] = append(a.waiters[phone], waiter)
+	a.mu.Unlock()
+
+	select {
+	case a.requests <- phone:
+	default:
+	}
+
+	select {
+	case code := <-waiter:
+		return code, nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		waiters := a.waiters[phone]
+		for i := range waiters {
+			if waiters[i] == waiter {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+
+		if len(waiters) > 0 {
+			a.waiters[phone] = waiters
+		} else {
+			delete(a.waiters, phone)
+		}
+
+		return "", ctx.Err()
+	}
+}
+
+// HTTPAuthorizer принимает коды подтверждения по HTTP.
+// GET возвращает JSON-массив номеров телефонов, для которых ожидается код,
+// POST с параметрами phone и code (в строке запроса или в форме) передает код.
+// Если задан Token, запросы должны содержать заголовок "Authorization: Bearer <Token>".
+// Без Token принимаются только запросы с loopback-адреса. Запросы через обратный прокси на той же машине
+// тоже приходят с loopback-адреса, поэтому при публикации обработчика наружу Token обязателен.
+type HTTPAuthorizer struct {
+	*ChannelAuthorizer
+	Token string
+}
+
+func NewHTTPAuthorizer(token string) *HTTPAuthorizer {
+	return &HTTPAuthorizer{
+		ChannelAuthorizer: NewChannelAuthorizer(),
+		Token:             token,
+	}
+}
+
+func (a *HTTPAuthorizer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.Token != "" {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	} else if !isLoopback(r.RemoteAddr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a.Pending())
+	case http.MethodPost:
+		phone, code := r.FormValue("phone"), strings.TrimSpace(r.FormValue("code"))
+		if phone == "" || code == "" {
+			http.Error(w, "phone and code are required", http.StatusBadRequest)
+			return
+		}
+
+		if err := a.Submit(phone, code); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// FileAuthorizer читает код подтверждения из файла или именованного канала (FIFO).
+// Подстрока "{phone}" в Path заменяется цифрами номера телефона.
+// Обычный файл учитывается, только если он изменен после запроса кода.
+type FileAuthorizer struct {
+	Path string `validate:"required"`
+
+	// PollInterval – интервал проверки обычного файла. По умолчанию 1 секунда.
+	PollInterval time.Duration
+
+	// Clock – часы для отсчета времени запроса и ожидания между проверками. По умолчанию based.StandardClock.
+	Clock based.Clock
+}
+
+func (a FileAuthorizer) GetConfirmationCode(ctx context.Context, phone string) (string, error) {
+	if err := based.Validate(a); err != nil {
+		return "", err
+	}
+
+	path := strings.ReplaceAll(a.Path, "{phone}", digits(phone))
+	interval := a.PollInterval
+	if interval == 0 {
+		interval = defaultFileAuthorizerPollInterval
+	}
+
+	clock := a.Clock
+	if clock == nil {
+		clock = based.StandardClock
+	}
+
+	start := clock.Now()
+	for {
+		stat, err := os.Stat(path)
+		switch {
+		case err == nil && stat.Mode()&os.ModeNamedPipe != 0:
+			return readFIFOCode(ctx, path)
+		case err == nil && stat.ModTime().After(start):
+			if code, err := readCode(path); err != nil || code != "" {
+				return code, err
+			}
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return "", errors.Wrap(err, "stat code file")
+		}
+
+		if err := sleep(ctx, clock, interval); err != nil {
+			return "", err
+		}
+	}
+}
+
+func readCode(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "open code file")
+	}
+
+	defer file.Close()
+	return scanCode(file)
+}
+
+func readFIFOCode(ctx context.Context, path string) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		code, err := readCode(path)
+		results <- result{code, err}
+	}()
+
+	select {
+	case result := <-results:
+		if result.err == nil && result.code == "" {
+			result.err = errors.New("empty confirmation code")
+		}
+
+		return result.code, result.err
+	case <-ctx.Done():
+		// opening the FIFO for writing unblocks the pending reader
+		if file, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			_ = file.Close()
+		}
+
+		return "", ctx.Err()
+	}
+}
+
+func scanCode(file *os.File) (string, error) {
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+
+	return "", errors.Wrap(scanner.Err(), "read code")
+}
+
+// RaceAuthorizers запрашивает код подтверждения у всех authorizers одновременно и возвращает первый полученный код.
+// Если timeout больше нуля, ожидание ограничено этим временем.
+func RaceAuthorizers(timeout time.Duration, authorizers ...Authorizer) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, phone string) (string, error) {
+		if len(authorizers) == 0 {
+			return "", errors.New("no authorizers")
+		}
+
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+
+		defer cancel()
+
+		type result struct {
+			code string
+			err  error
+		}
+
+		results := make(chan result, len(authorizers))
+		for _, authorizer := range authorizers {
+			go func() {
+				code, err := authorizer.GetConfirmationCode(ctx, phone)
+				results <- result{code, err}
+			}()
+		}
+
+		var lastErr error
+		for range authorizers {
+			result := <-results
+			if result.err == nil {
+				return result.code, nil
+			}
+
+			lastErr = result.err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return "", errors.Wrap(err, "wait for confirmation code")
+		}
+
+		return "", lastErr
+	})
+}
@@ -0,0 +1,191 @@
+package tinkoff_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+type codeResult struct {
+	code string
+	err  error
+}
+
+func getCodeAsync(ctx context.Context, authorizer tinkoff.Authorizer, phone string) <-chan codeResult {
+	results := make(chan codeResult, 1)
+	go func() {
+		code, err := authorizer.GetConfirmationCode(ctx, phone)
+		results <- codeResult{code, err}
+	}()
+
+	return results
+}
+
+func TestChannelAuthorizer(t *testing.T) {
+	authorizer := tinkoff.NewChannelAuthorizer()
+	assert.ErrorIs(t, authorizer.Submit(testPhone, "1234"), tinkoff.ErrNoPendingRequest)
+
+	t.Run("submit", func(t *testing.T) {
+		results := getCodeAsync(context.Background(), authorizer, testPhone)
+		assert.Equal(t, testPhone, <-authorizer.Requests())
+		assert.Equal(t, []string{testPhone}, authorizer.Pending())
+
+		require.NoError(t, authorizer.Submit(testPhone, "1234"))
+		result := <-results
+		require.NoError(t, result.err)
+		assert.Equal(t, "1234", result.code)
+		assert.Empty(t, authorizer.Pending())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		results := getCodeAsync(ctx, authorizer, testPhone)
+		<-authorizer.Requests()
+
+		cancel()
+		assert.ErrorIs(t, (<-results).err, context.Canceled)
+		assert.Empty(t, authorizer.Pending())
+		assert.ErrorIs(t, authorizer.Submit(testPhone, "1234"), tinkoff.ErrNoPendingRequest)
+	})
+}
+
+func TestHTTPAuthorizer(t *testing.T) {
+	serve := func(handler http.Handler, remoteAddr, token string, r *http.Request) *httptest.ResponseRecorder {
+		r.RemoteAddr = remoteAddr
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	submit := func(phone, code string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("phone="+phone+"&code="+code))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	t.Run("token", func(t *testing.T) {
+		authorizer := tinkoff.NewHTTPAuthorizer("secret")
+		get := func() *http.Request { return httptest.NewRequest(http.MethodGet, "/", nil) }
+
+		assert.Equal(t, http.StatusUnauthorized, serve(authorizer, "127.0.0.1:1234", "", get()).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(authorizer, "127.0.0.1:1234", "wrong", get()).Code)
+		assert.Equal(t, http.StatusNotFound, serve(authorizer, "192.0.2.1:1234", "secret", submit("79999999999", "1234")).Code)
+
+		results := getCodeAsync(context.Background(), authorizer, "79999999999")
+		<-authorizer.Requests()
+
+		w := serve(authorizer, "192.0.2.1:1234", "secret", get())
+		require.Equal(t, http.StatusOK, w.Code)
+		var pending []string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+		assert.Equal(t, []string{"79999999999"}, pending)
+
+		assert.Equal(t, http.StatusBadRequest, serve(authorizer, "192.0.2.1:1234", "secret", submit("79999999999", "")).Code)
+		assert.Equal(t, http.StatusNoContent, serve(authorizer, "192.0.2.1:1234", "secret", submit("79999999999", "1234")).Code)
+		assert.Equal(t, codeResult{code: "1234"}, <-results)
+
+		r := httptest.NewRequest(http.MethodDelete, "/", nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(authorizer, "192.0.2.1:1234", "secret", r).Code)
+	})
+
+	t.Run("loopback without token", func(t *testing.T) {
+		authorizer := tinkoff.NewHTTPAuthorizer("")
+		results := getCodeAsync(context.Background(), authorizer, "79999999999")
+		<-authorizer.Requests()
+
+		assert.Equal(t, http.StatusForbidden, serve(authorizer, "192.0.2.1:1234", "", submit("79999999999", "1234")).Code)
+		assert.Equal(t, http.StatusNoContent, serve(authorizer, "[::1]:1234", "", submit("79999999999", "1234")).Code)
+		assert.Equal(t, codeResult{code: "1234"}, <-results)
+	})
+}
+
+func TestFileAuthorizer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "79999999999.code")
+
+	// the code file is stale unless it is modified after the code is requested
+	require.NoError(t, os.WriteFile(path, []byte("0000\n"), 0600))
+	start := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, start.Add(-time.Minute), start.Add(-time.Minute)))
+
+	clock := newFakeClock(start)
+	authorizer := tinkoff.FileAuthorizer{
+		Path:         filepath.Join(dir, "{phone}.code"),
+		PollInterval: time.Second,
+		Clock:        clock,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := getCodeAsync(ctx, authorizer, testPhone)
+	clock.WaitTimer()
+
+	require.NoError(t, os.WriteFile(path, []byte(" 1234 \n"), 0600))
+	require.NoError(t, os.Chtimes(path, start.Add(time.Minute), start.Add(time.Minute)))
+	clock.Advance(time.Second)
+	assert.Equal(t, codeResult{code: "1234"}, <-results)
+
+	// an empty path fails immediately instead of polling until the context expires
+	_, err := tinkoff.FileAuthorizer{Clock: clock}.GetConfirmationCode(ctx, testPhone)
+	assert.Error(t, err)
+
+	results = getCodeAsync(ctx, tinkoff.FileAuthorizer{Path: filepath.Join(dir, "missing"), Clock: clock}, testPhone)
+	clock.WaitTimer()
+	cancel()
+	assert.ErrorIs(t, (<-results).err, context.Canceled)
+}
+
+func TestRaceAuthorizers(t *testing.T) {
+	block := tinkoff.AuthorizerFunc(func(ctx context.Context, _ string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	fail := tinkoff.AuthorizerFunc(func(context.Context, string) (string, error) {
+		return "", errors.New("failed")
+	})
+
+	code := tinkoff.AuthorizerFunc(func(context.Context, string) (string, error) {
+		return "1234", nil
+	})
+
+	ctx := context.Background()
+
+	t.Run("first code wins", func(t *testing.T) {
+		result, err := tinkoff.RaceAuthorizers(0, block, fail, code).GetConfirmationCode(ctx, testPhone)
+		require.NoError(t, err)
+		assert.Equal(t, "1234", result)
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		_, err := tinkoff.RaceAuthorizers(0, fail, fail).GetConfirmationCode(ctx, testPhone)
+		assert.EqualError(t, err, "failed")
+	})
+
+	t.Run("no authorizers", func(t *testing.T) {
+		_, err := tinkoff.RaceAuthorizers(0).GetConfirmationCode(ctx, testPhone)
+		assert.Error(t, err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := tinkoff.RaceAuthorizers(10*time.Millisecond, block, fail).GetConfirmationCode(ctx, testPhone)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
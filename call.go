@@ -0,0 +1,113 @@
+package tinkoff
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-querystring/query"
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+)
+
+// Endpoint описывает эндпоинт API, для которого в клиенте нет отдельного метода.
+// In – тип параметров запроса, поля которого кодируются по тегам `url` (github.com/google/go-querystring).
+// Out – тип ответа: поле payload для /common/v1/*, либо тело ответа целиком для /invest-gw/*.
+//
+// Пример:
+//
+//	var loyaltyEndpoint = tinkoff.Endpoint[LoyaltyIn, LoyaltyOut]{
+//		Path: "/common/v1/loyalty",
+//		Auth: tinkoff.AuthForce,
+//	}
+//
+//	out, err := tinkoff.Call(ctx, client, loyaltyEndpoint, LoyaltyIn{Account: "123"})
+type Endpoint[In, Out any] struct {
+	// Path – путь эндпоинта, например "/common/v1/operations".
+	Path string `validate:"required"`
+
+	// Method – HTTP-метод. По умолчанию POST для /common/v1/* и GET для /invest-gw/*.
+	Method string
+
+	// Auth определяет, как запрос использует сессию.
+	Auth AuthMode
+
+	// Invest равно true для эндпоинтов /invest-gw/* (см. Exchange.Invest).
+	Invest bool
+
+	// ExpectedCode – ожидаемый resultCode. По умолчанию "OK". Не используется для /invest-gw/*.
+	ExpectedCode string
+}
+
+func (e Endpoint[In, Out]) exchange(values url.Values) *Exchange {
+	exchange := &Exchange{
+		Path:         e.Path,
+		Method:       e.Method,
+		Auth:         e.Auth,
+		Invest:       e.Invest,
+		Values:       values,
+		ExpectedCode: e.ExpectedCode,
+	}
+
+	if exchange.Method == "" {
+		exchange.Method = http.MethodPost
+		if exchange.Invest {
+			exchange.Method = http.MethodGet
+		}
+	}
+
+	if exchange.ExpectedCode == "" && !exchange.Invest {
+		exchange.ExpectedCode = "OK"
+	}
+
+	return exchange
+}
+
+// commonEndpoint возвращает Endpoint для встроенного запроса к /common/v1/* с другим типом ответа Out.
+func commonEndpoint[Out any, In interface {
+	auth() AuthMode
+	path() string
+	exprc() string
+}](in In) Endpoint[In, Out] {
+	return Endpoint[In, Out]{
+		Path:         in.path(),
+		Auth:         in.auth(),
+		ExpectedCode: in.exprc(),
+	}
+}
+
+// Call выполняет запрос к эндпоинту endpoint с параметрами in.
+// Запрос выполняется так же, как запросы встроенных методов клиента: с получением сессии и повторной авторизацией,
+// ограничением частоты запросов, повторами и middleware из ClientParams.Middleware.
+func Call[In, Out any](ctx context.Context, c *Client, endpoint Endpoint[In, Out], in In) (*Out, error) {
+	if err := based.Validate(endpoint); err != nil {
+		return nil, err
+	}
+
+	values, err := query.Values(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode request values")
+	}
+
+	resp, err := c.handler.Exchange(ctx, endpoint.exchange(values))
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Invest {
+		var out Out
+		if err := json.Unmarshal(resp.Body, &out); err != nil {
+			return nil, errors.Wrap(err, "unmarshal response body")
+		}
+
+		return &out, nil
+	}
+
+	var out commonResponse[Out]
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		return nil, errors.Wrap(err, "decode response body")
+	}
+
+	return &out.Payload, nil
+}
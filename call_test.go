@@ -0,0 +1,83 @@
+package tinkoff_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+type callIn struct {
+	Account string `url:"account,omitempty"`
+}
+
+func TestCall(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	server.AddAccounts(tinkoff.Account{Id: "1"}, tinkoff.Account{Id: "2"})
+	server.AddInvestOperationTypes(tinkoff.InvestOperationType{Category: "income", OperationType: "OPERATION_TYPE_COUPON"})
+
+	var methods []string
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{
+		Middleware: []tinkoff.Middleware{func(next tinkoff.ExchangeHandler) tinkoff.ExchangeHandler {
+			return tinkoff.ExchangeHandlerFunc(func(ctx context.Context, exchange *tinkoff.Exchange) (*tinkoff.ExchangeResponse, error) {
+				methods = append(methods, exchange.Method+" "+exchange.Path)
+				return next.Exchange(ctx, exchange)
+			})
+		}},
+	})
+
+	t.Run("common", func(t *testing.T) {
+		methods = nil
+		out, err := tinkoff.Call(ctx, client, tinkoff.Endpoint[callIn, tinkoff.AccountsLightIbOut]{
+			Path: "/common/v1/accounts_light_ib",
+			Auth: tinkoff.AuthForce,
+		}, callIn{})
+
+		require.NoError(t, err)
+		require.Len(t, *out, 2)
+		assert.Equal(t, "1", (*out)[0].Id)
+		assert.Equal(t, "POST /common/v1/accounts_light_ib", methods[len(methods)-1])
+	})
+
+	t.Run("unexpected code", func(t *testing.T) {
+		_, err := tinkoff.Call(ctx, client, tinkoff.Endpoint[callIn, tinkoff.AccountsLightIbOut]{
+			Path:         "/common/v1/accounts_light_ib",
+			Auth:         tinkoff.AuthForce,
+			ExpectedCode: "WAITING_CONFIRMATION",
+		}, callIn{})
+
+		var apiErr *tinkoff.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "OK", apiErr.ResultCode)
+		assert.Equal(t, "WAITING_CONFIRMATION", apiErr.ExpectedCode)
+		assert.Equal(t, "/common/v1/accounts_light_ib", apiErr.Path)
+	})
+
+	t.Run("invest", func(t *testing.T) {
+		methods = nil
+		out, err := tinkoff.Call(ctx, client, tinkoff.Endpoint[callIn, tinkoff.InvestOperationTypesOut]{
+			Path:   "/invest-gw/ca-operations/api/v1/operations/types",
+			Invest: true,
+		}, callIn{})
+
+		require.NoError(t, err)
+		assert.Equal(t, &tinkoff.InvestOperationTypesOut{OperationsTypes: []tinkoff.InvestOperationType{
+			{Category: "income", OperationType: "OPERATION_TYPE_COUPON"},
+		}}, out)
+		assert.Equal(t, []string{"GET /invest-gw/ca-operations/api/v1/operations/types"}, methods)
+	})
+
+	t.Run("path is required", func(t *testing.T) {
+		requests := server.Requests("/common/v1/accounts_light_ib")
+		_, err := tinkoff.Call(ctx, client, tinkoff.Endpoint[callIn, tinkoff.AccountsLightIbOut]{Auth: tinkoff.AuthForce}, callIn{})
+		assert.Error(t, err)
+		assert.Equal(t, requests, server.Requests("/common/v1/accounts_light_ib"))
+	})
+}
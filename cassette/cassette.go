@@ -0,0 +1,294 @@
+// Package cassette предоставляет http.RoundTripper для записи реального взаимодействия с API в файл
+// и последующего воспроизведения записанных ответов без обращения к серверу.
+// Идентификаторы сессий, номер телефона, пароль, код подтверждения и номера карт при записи заменяются на заглушки.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const redacted = "REDACTED"
+
+var (
+	redactedParams = map[string]bool{
+		"sessionid":        true,
+		"sessionId":        true,
+		"phone":            true,
+		"password":         true,
+		"confirmationData": true,
+	}
+
+	redactedKeys = map[string]bool{
+		"cardNumber": true,
+		"pan":        true,
+	}
+
+	cardNumberRegexp = regexp.MustCompile(`^[2-6]\d{3,5}[\d*]{4,9}\d{4}$`)
+)
+
+type Request struct {
+	Method string     `json:"method"`
+	Path   string     `json:"path"`
+	Query  url.Values `json:"query,omitempty"`
+	Form   url.Values `json:"form,omitempty"`
+}
+
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette – содержимое файла с записанным взаимодействием.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load загружает Cassette из файла.
+func Load(path string) (*Cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open file")
+	}
+
+	defer file.Close()
+
+	var cassette Cassette
+	if err := json.NewDecoder(file).Decode(&cassette); err != nil {
+		return nil, errors.Wrap(err, "decode json")
+	}
+
+	return &cassette, nil
+}
+
+// Save сохраняет Cassette в файл.
+// Файл перезаписывается атомарно.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode json")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "create parent directory")
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "write temporary file")
+	}
+
+	return errors.Wrap(os.Rename(tmp, path), "rename temporary file")
+}
+
+// newRequest создает Request из http.Request с заменой секретов.
+// Также возвращаются исходные значения секретов.
+func newRequest(req *http.Request) (Request, []string, error) {
+	query := req.URL.Query()
+	recorded := Request{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  redactParams(query),
+	}
+
+	secrets := secretParams(query)
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, secrets, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return recorded, nil, errors.Wrap(err, "read request body")
+	}
+
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return recorded, nil, errors.Wrap(err, "parse form")
+		}
+
+		recorded.Form = redactParams(form)
+		secrets = append(secrets, secretParams(form)...)
+	}
+
+	return recorded, secrets, nil
+}
+
+func (r Request) matches(other Request) bool {
+	return r.Method == other.Method &&
+		r.Path == other.Path &&
+		r.Query.Encode() == other.Query.Encode() &&
+		r.Form.Encode() == other.Form.Encode()
+}
+
+func redactParams(values url.Values) url.Values {
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make(url.Values, len(values))
+	for key, value := range values {
+		if redactedParams[key] {
+			result[key] = []string{redacted}
+		} else {
+			result[key] = value
+		}
+	}
+
+	return result
+}
+
+func secretParams(values url.Values) []string {
+	var secrets []string
+	for key, value := range values {
+		if redactedParams[key] {
+			secrets = append(secrets, value...)
+		}
+	}
+
+	return secrets
+}
+
+// redactBody заменяет номера карт в JSON-ответе и все известные секреты в теле ответа.
+func redactBody(body []byte, secrets []string) string {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err == nil {
+		if data, err := json.Marshal(redactValue("", value)); err == nil {
+			body = data
+		}
+	}
+
+	str := string(body)
+	for _, secret := range secrets {
+		str = strings.ReplaceAll(str, secret, redacted)
+	}
+
+	return str
+}
+
+func redactValue(key string, value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			value[key] = redactValue(key, child)
+		}
+
+		return value
+
+	case []any:
+		for i, child := range value {
+			value[i] = redactValue(key, child)
+		}
+
+		return value
+
+	case string:
+		if redactedKeys[key] || isCardNumber(value) {
+			return maskCardNumber(value)
+		}
+
+		return value
+
+	case json.Number:
+		if redactedKeys[key] || isCardNumber(value.String()) {
+			return maskCardNumber(value.String())
+		}
+
+		return value
+
+	default:
+		return value
+	}
+}
+
+// isCardNumber проверяет, что value похоже на номер карты: маскированный (например, "553691******1234")
+// или полный номер, проходящий проверку по алгоритму Луна.
+func isCardNumber(value string) bool {
+	if !cardNumberRegexp.MatchString(value) {
+		return false
+	}
+
+	if strings.Contains(value, "*") {
+		return true
+	}
+
+	return luhn(value)
+}
+
+func luhn(digits string) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+func maskCardNumber(value string) string {
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
+
+type secrets struct {
+	values map[string]bool
+	mu     sync.Mutex
+}
+
+func (s *secrets) add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = make(map[string]bool)
+	}
+
+	for _, value := range values {
+		// too short values would corrupt unrelated data
+		if len(value) >= 4 {
+			s.values[value] = true
+		}
+	}
+}
+
+func (s *secrets) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]string, 0, len(s.values))
+	for value := range s.values {
+		list = append(list, value)
+	}
+
+	return list
+}
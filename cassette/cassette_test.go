@@ -0,0 +1,101 @@
+package cassette
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsCardNumber(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected bool
+	}{
+		{"4111111111111111", true},
+		{"5536913812345672", true},
+		{"5536913812345670", false},
+		{"2200700112345672", true},
+		{"220070******1234", true},
+		{"5536 9138 1234 5678", false},
+		{"79991234567", false},
+		{"1234567812345670", false},
+		{"4111111111111", false},
+		{"411111111111111111", false},
+		{"6222021111111111112", true},
+		{"REDACTED", false},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isCardNumber(tc.value))
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	body := `{"payload":{"pan":"1234","number":"4111111111111111","masked":"220070******1234","id":"12345678901","session":"secret-session"}}`
+	redactedBody := redactBody([]byte(body), []string{"secret-session"})
+
+	assert.NotContains(t, redactedBody, "4111111111111111")
+	assert.NotContains(t, redactedBody, "220070")
+	assert.NotContains(t, redactedBody, "secret-session")
+	assert.Contains(t, redactedBody, `"number":"************1111"`)
+	assert.Contains(t, redactedBody, `"masked":"************1234"`)
+	assert.Contains(t, redactedBody, `"pan":"****"`)
+	assert.Contains(t, redactedBody, `"id":"12345678901"`)
+}
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case sessionPath:
+			_, _ = w.Write([]byte(`{"resultCode":"OK","payload":"session-id"}`))
+		default:
+			_, _ = w.Write([]byte(`{"resultCode":"OK","payload":{"sessionid":"session-id","cardNumber":"4111111111111111"}}`))
+		}
+	}))
+
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := NewRecorder(path, nil)
+	client := &http.Client{Transport: recorder}
+
+	resp, err := client.Get(server.URL + sessionPath)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	resp, err = client.PostForm(server.URL+"/common/v1/accounts_light_ib?sessionid=session-id", url.Values{"phone": {"+79999999999"}})
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "cassette should not be saved before Close")
+
+	require.NoError(t, recorder.Close())
+
+	cassette, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 2)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"session-id", "+79999999999", "4111111111111111"} {
+		assert.False(t, strings.Contains(string(data), secret), "cassette contains %s", secret)
+	}
+
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+	client = &http.Client{Transport: replayer}
+
+	resp, err = client.PostForm(server.URL+"/common/v1/accounts_light_ib?sessionid=other", url.Values{"phone": {"+78888888888"}})
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,95 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const sessionPath = "/common/v1/session"
+
+// Recorder записывает запросы и ответы в файл.
+// Записанные взаимодействия накапливаются в памяти и сохраняются в файл при вызове Close.
+type Recorder struct {
+	path      string
+	transport http.RoundTripper
+	secrets   secrets
+	cassette  Cassette
+	mu        sync.Mutex
+}
+
+// NewRecorder создает Recorder, который выполняет запросы с помощью transport и сохраняет их в path.
+// Если transport равен nil, используется http.DefaultTransport.
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Recorder{
+		path:      path,
+		transport: transport,
+	}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, secrets, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.secrets.add(secrets...)
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "read response body")
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if strings.HasSuffix(req.URL.Path, sessionPath) {
+		var session struct {
+			Payload string `json:"payload"`
+		}
+
+		if err := json.Unmarshal(body, &session); err == nil {
+			r.secrets.add(session.Payload)
+		}
+	}
+
+	response := Response{
+		Status: resp.StatusCode,
+		Body:   redactBody(body, r.secrets.list()),
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		response.Header = http.Header{"Content-Type": {contentType}}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  request,
+		Response: response,
+	})
+
+	return resp, nil
+}
+
+// Close сохраняет записанные взаимодействия в файл.
+// Recorder можно продолжать использовать после Close; при повторном вызове файл перезаписывается.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Wrap(r.cassette.Save(r.path), "save cassette")
+}
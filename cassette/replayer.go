@@ -0,0 +1,78 @@
+package cassette
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Replayer воспроизводит записанные ответы.
+// Запрос сопоставляется с записью по методу, пути, параметрам запроса и телу формы (без учета секретов).
+// Одинаковые запросы получают записанные ответы по порядку; после исчерпания записей повторяется последний ответ.
+type Replayer struct {
+	cassette *Cassette
+	used     []bool
+	mu       sync.Mutex
+}
+
+// NewReplayer создает Replayer из записи в файле path.
+func NewReplayer(path string) (*Replayer, error) {
+	cassette, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replayer{
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, _, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !interaction.Request.matches(request) {
+			continue
+		}
+
+		last = i
+		if !r.used[i] {
+			r.used[i] = true
+			break
+		}
+	}
+
+	if last < 0 {
+		return nil, errors.Errorf("no recorded interaction for %s %s", req.Method, req.URL.Path)
+	}
+
+	response := r.cassette.Interactions[last].Response
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status)),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,500 @@
+package tinkoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultCDPTimeout      = 5 * time.Minute
+	cdpStartTimeout        = 30 * time.Second
+	cdpWaitDelay           = 5 * time.Second
+	cdpOrigin              = "http://localhost"
+	cdpDevToolsLinePrefix  = "DevTools listening on "
+	cdpSessionCookieName   = "psid"
+	cdpDefaultWindowWidth  = 1920
+	cdpDefaultWindowHeight = 1080
+)
+
+var cdpExecNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// CDPAuthFlow производит аутентификацию в локально запущенном Chromium через протокол Chrome DevTools.
+// В отличие от SeleniumAuthFlow, не требует отдельного WebDriver-сервера.
+type CDPAuthFlow struct {
+	// ExecPath – путь к исполняемому файлу Chromium. По умолчанию ищется в PATH.
+	ExecPath string
+
+	// ShowWindow запускает браузер с окном (по умолчанию браузер запускается в headless-режиме).
+	ShowWindow bool
+
+	// Args – дополнительные аргументы командной строки браузера.
+	Args []string
+
+	// Timeout ограничивает время авторизации. По умолчанию 5 минут.
+	Timeout time.Duration
+
+	// Steps – шаги авторизации на странице входа. По умолчанию используется DefaultLoginSteps.
+	// Таблица проверяется до запуска браузера: нужен хотя бы один терминальный шаг и только известные действия.
+	Steps []LoginStep
+}
+
+func (f *CDPAuthFlow) String() string { return "cdp" }
+
+func (f *CDPAuthFlow) authorize(ctx context.Context, c *Client, authorizer Authorizer) (*Session, error) {
+	if err := validateLoginSteps(f.Steps); err != nil {
+		return nil, err
+	}
+
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = defaultCDPTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	browser, err := f.start(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "start browser")
+	}
+
+	defer browser.close()
+
+	page, err := browser.newPage(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "open page")
+	}
+
+	if err := page.call(ctx, "Page.navigate", map[string]any{"url": c.baseURL + "/login"}, nil); err != nil {
+		return nil, errors.Wrap(err, "open login page")
+	}
+
+	if err := runLogin(ctx, c, authorizer, page, f.Steps); err != nil {
+		return nil, err
+	}
+
+	var cookies struct {
+		Cookies []struct {
+			Name    string  `json:"name"`
+			Value   string  `json:"value"`
+			Expires float64 `json:"expires"`
+		} `json:"cookies"`
+	}
+
+	if err := page.call(ctx, "Network.getCookies", map[string]any{"urls": []string{c.baseURL}}, &cookies); err != nil {
+		return nil, errors.Wrap(err, "get cookies")
+	}
+
+	for _, cookie := range cookies.Cookies {
+		if cookie.Name == cdpSessionCookieName {
+			session := &Session{ID: cookie.Value}
+			if cookie.Expires > 0 {
+				session.ExpiresAt = time.Unix(int64(cookie.Expires), 0)
+			}
+
+			return session, nil
+		}
+	}
+
+	return nil, errors.New("session cookie not found")
+}
+
+func (f *CDPAuthFlow) execPath() (string, error) {
+	if f.ExecPath != "" {
+		return f.ExecPath, nil
+	}
+
+	for _, name := range cdpExecNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", errors.New("chromium executable not found")
+}
+
+func (f *CDPAuthFlow) start(ctx context.Context) (*cdpBrowser, error) {
+	path, err := f.execPath()
+	if err != nil {
+		return nil, err
+	}
+
+	dataDir, err := os.MkdirTemp("", "tbank-cdp-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create user data directory")
+	}
+
+	args := []string{
+		"--remote-debugging-port=0",
+		// only the origin sent by dialCDP may connect to DevTools
+		"--remote-allow-origins=" + cdpOrigin,
+		"--user-data-dir=" + dataDir,
+		"--no-first-run",
+		"--no-default-browser-check",
+		fmt.Sprintf("--window-size=%d,%d", cdpDefaultWindowWidth, cdpDefaultWindowHeight),
+	}
+
+	if !f.ShowWindow {
+		args = append(args, "--headless=new")
+	}
+
+	args = append(append(args, f.Args...), "about:blank")
+
+	// the browser must outlive the request context until it is closed explicitly
+	cmd := exec.Command(path, args...)
+	stderr := &devToolsWriter{urls: make(chan string, 1)}
+	cmd.Stderr = stderr
+	// child processes may keep stderr open after the browser is killed
+	cmd.WaitDelay = cdpWaitDelay
+	if err := cmd.Start(); err != nil {
+		_ = os.RemoveAll(dataDir)
+		return nil, errors.Wrap(err, "start process")
+	}
+
+	browser := &cdpBrowser{cmd: cmd, dataDir: dataDir, exited: make(chan struct{})}
+	go func() {
+		defer close(browser.exited)
+		_ = cmd.Wait()
+	}()
+
+	wsURL, err := browser.devToolsURL(ctx, stderr.urls)
+	if err != nil {
+		browser.close()
+		return nil, err
+	}
+
+	if browser.conn, err = dialCDP(wsURL); err != nil {
+		browser.close()
+		return nil, err
+	}
+
+	return browser, nil
+}
+
+// devToolsWriter ищет адрес DevTools в выводе браузера. Остальной вывод отбрасывается.
+type devToolsWriter struct {
+	urls  chan string
+	line  []byte
+	found bool
+}
+
+func (w *devToolsWriter) Write(p []byte) (int, error) {
+	if w.found {
+		return len(p), nil
+	}
+
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := strings.TrimSpace(string(w.line[:i]))
+		w.line = w.line[i+1:]
+		if url, ok := strings.CutPrefix(line, cdpDevToolsLinePrefix); ok {
+			w.found, w.line = true, nil
+			w.urls <- url
+			break
+		}
+	}
+
+	return len(p), nil
+}
+
+// devToolsURL ожидает адрес DevTools из вывода браузера.
+func (b *cdpBrowser) devToolsURL(ctx context.Context, urls <-chan string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cdpStartTimeout)
+	defer cancel()
+
+	select {
+	case url := <-urls:
+		return url, nil
+	case <-b.exited:
+		// the output is fully written once the process has been waited for
+		select {
+		case url := <-urls:
+			return url, nil
+		default:
+			return "", errors.New("browser exited before DevTools became available")
+		}
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "wait for DevTools")
+	}
+}
+
+type cdpBrowser struct {
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	dataDir string
+	conn    *cdpConn
+}
+
+func (b *cdpBrowser) newPage(ctx context.Context) (*cdpPage, error) {
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+
+	if err := b.conn.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return nil, errors.Wrap(err, "create target")
+	}
+
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	if err := b.conn.call(ctx, "", "Target.attachToTarget", map[string]any{
+		"targetId": target.TargetID,
+		"flatten":  true,
+	}, &attached); err != nil {
+		return nil, errors.Wrap(err, "attach to target")
+	}
+
+	return &cdpPage{conn: b.conn, sessionID: attached.SessionID}, nil
+}
+
+func (b *cdpBrowser) close() {
+	if b.conn != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_ = b.conn.call(ctx, "", "Browser.close", nil, nil)
+		cancel()
+		_ = b.conn.close()
+	}
+
+	_ = b.cmd.Process.Kill()
+	<-b.exited
+
+	_ = os.RemoveAll(b.dataDir)
+}
+
+type cdpPage struct {
+	conn      *cdpConn
+	sessionID string
+}
+
+func (p *cdpPage) call(ctx context.Context, method string, params any, result any) error {
+	return p.conn.call(ctx, p.sessionID, method, params, result)
+}
+
+// evaluate выполняет выражение на странице и возвращает его значение.
+func (p *cdpPage) evaluate(ctx context.Context, expression string, result any) error {
+	var resp struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+
+	if err := p.call(ctx, "Runtime.evaluate", map[string]any{
+		"expression":    expression,
+		"returnByValue": true,
+	}, &resp); err != nil {
+		return err
+	}
+
+	if resp.ExceptionDetails != nil {
+		return errors.Errorf("evaluate: %s", resp.ExceptionDetails.Text)
+	}
+
+	if result == nil || len(resp.Result.Value) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Result.Value, result)
+}
+
+func (p *cdpPage) findElements(ctx context.Context, xpath string) ([]browserElement, error) {
+	var count int
+	if err := p.evaluate(ctx, xpathSnapshot(xpath)+".snapshotLength", &count); err != nil {
+		return nil, err
+	}
+
+	elements := make([]browserElement, count)
+	for i := range elements {
+		elements[i] = cdpElement{page: p, expression: fmt.Sprintf("%s.snapshotItem(%d)", xpathSnapshot(xpath), i)}
+	}
+
+	return elements, nil
+}
+
+func xpathSnapshot(xpath string) string {
+	quoted, _ := json.Marshal(xpath)
+	return fmt.Sprintf("document.evaluate(%s, document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null)", quoted)
+}
+
+type cdpElement struct {
+	page       *cdpPage
+	expression string
+}
+
+func (el cdpElement) isDisplayed(ctx context.Context) (bool, error) {
+	var displayed bool
+	err := el.page.evaluate(ctx, fmt.Sprintf(`(el => !!el && el.getClientRects().length > 0 && getComputedStyle(el).visibility !== "hidden")(%s)`, el.expression), &displayed)
+	return displayed, err
+}
+
+func (el cdpElement) sendKeys(ctx context.Context, text string, enter bool) error {
+	if err := el.page.evaluate(ctx, fmt.Sprintf(`%s.focus()`, el.expression), nil); err != nil {
+		return errors.Wrap(err, "focus")
+	}
+
+	if err := el.page.call(ctx, "Input.insertText", map[string]any{"text": text}, nil); err != nil {
+		return errors.Wrap(err, "insert text")
+	}
+
+	if !enter {
+		return nil
+	}
+
+	for _, eventType := range []string{"keyDown", "keyUp"} {
+		event := map[string]any{
+			"type":                  eventType,
+			"key":                   "Enter",
+			"code":                  "Enter",
+			"windowsVirtualKeyCode": 13,
+		}
+
+		if eventType == "keyDown" {
+			event["text"] = "\r"
+		}
+
+		if err := el.page.call(ctx, "Input.dispatchKeyEvent", event, nil); err != nil {
+			return errors.Wrap(err, "press enter")
+		}
+	}
+
+	return nil
+}
+
+func (el cdpElement) click(ctx context.Context) error {
+	return el.page.evaluate(ctx, fmt.Sprintf(`%s.click()`, el.expression), nil)
+}
+
+// cdpConn – соединение с браузером по протоколу Chrome DevTools.
+type cdpConn struct {
+	ws      *websocket.Conn
+	seq     int64
+	pending map[int64]chan cdpMessage
+	err     error
+	mu      sync.Mutex
+}
+
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    any             `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func dialCDP(url string) (*cdpConn, error) {
+	ws, err := websocket.Dial(url, "", cdpOrigin)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial DevTools")
+	}
+
+	conn := &cdpConn{
+		ws:      ws,
+		pending: make(map[int64]chan cdpMessage),
+	}
+
+	go conn.read()
+	return conn, nil
+}
+
+func (c *cdpConn) read() {
+	for {
+		var msg cdpMessage
+		if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+			c.mu.Lock()
+			c.err = err
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+
+			c.mu.Unlock()
+			return
+		}
+
+		if msg.ID == 0 {
+			// events are not used
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[msg.ID]
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+func (c *cdpConn) call(ctx context.Context, sessionID, method string, params any, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return errors.Wrap(c.err, "connection closed")
+	}
+
+	c.seq++
+	id := c.seq
+	ch := make(chan cdpMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if params == nil {
+		params = struct{}{}
+	}
+
+	if err := websocket.JSON.Send(c.ws, cdpMessage{ID: id, SessionID: sessionID, Method: method, Params: params}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return errors.Wrapf(err, "send %s", method)
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return errors.Errorf("%s: connection closed", method)
+		}
+
+		if msg.Error != nil {
+			return errors.Errorf("%s: %s (%d)", method, msg.Error.Message, msg.Error.Code)
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		return errors.Wrapf(json.Unmarshal(msg.Result, result), "unmarshal %s result", method)
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (c *cdpConn) close() error {
+	return c.ws.Close()
+}
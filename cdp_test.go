@@ -0,0 +1,237 @@
+package tinkoff_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+func TestCDPAuthFlow_Chromium(t *testing.T) {
+	var execPath string
+	for _, name := range []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"} {
+		if path, err := exec.LookPath(name); err == nil {
+			execPath = path
+			break
+		}
+	}
+
+	if execPath == "" {
+		t.Skip("chromium executable not found")
+	}
+
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{
+		AuthFlow: &tinkoff.CDPAuthFlow{ExecPath: execPath, Args: []string{"--no-sandbox"}},
+	})
+
+	_, err := client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, server.Requests("/login/submit"))
+}
+
+func TestCDPAuthFlow_FakeDevTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake browser executable requires a POSIX shell")
+	}
+
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	devTools := &fakeDevTools{t: t, loginURL: server.URL + "/login"}
+	ws := httptest.NewServer(websocket.Handler(devTools.serve))
+	defer ws.Close()
+
+	// the fake browser only records its arguments, announces the DevTools address and waits to be killed
+	dir := t.TempDir()
+	execPath := filepath.Join(dir, "chromium")
+	argsPath := filepath.Join(dir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > '%s'\necho 'DevTools listening on ws%s' >&2\nexec sleep 60\n",
+		argsPath, strings.TrimPrefix(ws.URL, "http"))
+	require.NoError(t, os.WriteFile(execPath, []byte(script), 0700))
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{
+		AuthFlow: &tinkoff.CDPAuthFlow{ExecPath: execPath},
+	})
+
+	_, err := client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, server.Requests("/login/submit"))
+	assert.Equal(t, []string{server.Phone, server.Password, server.Code}, devTools.inputs)
+
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	assert.Contains(t, strings.Fields(string(args)), "--remote-allow-origins=http://localhost")
+}
+
+func TestCDPAuthFlow_BrowserExits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake browser executable requires a POSIX shell")
+	}
+
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	execPath := filepath.Join(t.TempDir(), "chromium")
+	require.NoError(t, os.WriteFile(execPath, []byte("#!/bin/sh\necho 'no DevTools' >&2\nexit 1\n"), 0700))
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{
+		AuthFlow: &tinkoff.CDPAuthFlow{ExecPath: execPath},
+	})
+
+	_, err := client.AccountsLightIb(ctx)
+	assert.ErrorContains(t, err, "browser exited before DevTools became available")
+}
+
+// fakeDevTools отвечает на команды CDPAuthFlow, имитируя страницу входа tinkofftest:
+// поля отображаются по очереди, а после ввода кода данные отправляются на /login/submit.
+type fakeDevTools struct {
+	t        *testing.T
+	loginURL string
+	stage    int
+	focused  int
+	inputs   []string
+	session  string
+}
+
+// fakeLoginElements – элементы страницы входа в порядке их появления.
+var fakeLoginElements = []string{"phone-input", "password-input", "otp-input", "/new-product/"}
+
+type fakeCDPMessage struct {
+	ID        int64           `json:"id"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    any             `json:"result,omitempty"`
+}
+
+func (d *fakeDevTools) serve(ws *websocket.Conn) {
+	assert.Equal(d.t, "http://localhost", ws.Config().Origin.String())
+	for {
+		var msg fakeCDPMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+
+		var params map[string]any
+		_ = json.Unmarshal(msg.Params, &params)
+		result, err := d.handle(msg.Method, params)
+		if !assert.NoError(d.t, err, msg.Method) {
+			return
+		}
+
+		if err := websocket.JSON.Send(ws, fakeCDPMessage{ID: msg.ID, SessionID: msg.SessionID, Result: result}); err != nil {
+			return
+		}
+	}
+}
+
+func (d *fakeDevTools) handle(method string, params map[string]any) (any, error) {
+	switch method {
+	case "Target.createTarget":
+		return map[string]any{"targetId": "target"}, nil
+	case "Target.attachToTarget":
+		return map[string]any{"sessionId": "session"}, nil
+	case "Page.navigate":
+		if params["url"] != d.loginURL {
+			return nil, fmt.Errorf("unexpected url %v", params["url"])
+		}
+
+		return map[string]any{}, nil
+	case "Runtime.evaluate":
+		return d.evaluate(params["expression"].(string)), nil
+	case "Input.insertText":
+		if d.focused != d.stage {
+			return nil, fmt.Errorf("input into hidden element %d", d.focused)
+		}
+
+		d.inputs = append(d.inputs, params["text"].(string))
+		if fakeLoginElements[d.stage] == "otp-input" {
+			return map[string]any{}, d.submit()
+		}
+
+		return map[string]any{}, nil
+	case "Input.dispatchKeyEvent":
+		if params["type"] == "keyDown" {
+			d.stage++
+		}
+
+		return map[string]any{}, nil
+	case "Network.getCookies":
+		return map[string]any{"cookies": []map[string]any{{"name": "psid", "value": d.session}}}, nil
+	case "Browser.close":
+		return map[string]any{}, nil
+	default:
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+}
+
+func (d *fakeDevTools) evaluate(expression string) any {
+	element := -1
+	for i, name := range fakeLoginElements {
+		if strings.Contains(expression, name) {
+			element = i
+		}
+	}
+
+	var value any
+	switch {
+	case strings.HasSuffix(expression, ".snapshotLength"):
+		if element >= 0 {
+			value = 1
+		} else {
+			value = 0
+		}
+	case strings.Contains(expression, "getClientRects"):
+		value = element == d.stage
+	case strings.HasSuffix(expression, ".focus()"):
+		d.focused = element
+	}
+
+	return map[string]any{"result": map[string]any{"value": value}}
+}
+
+func (d *fakeDevTools) submit() error {
+	resp, err := http.PostForm(d.loginURL+"/submit", url.Values{
+		"phone":    {d.inputs[0]},
+		"password": {d.inputs[1]},
+		"code":     {d.inputs[2]},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("login submit: %s", resp.Status)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "psid" {
+			d.session = cookie.Value
+			d.stage++
+		}
+	}
+
+	return nil
+}
@@ -4,32 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/google/go-querystring/query"
 	"github.com/jfk9w-go/based"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	baseURL      = "https://www.tbank.ru"
-	baseApiURL   = baseURL + "/api"
-	pingInterval = time.Minute
+	baseURL            = "https://www.tbank.ru"
+	pingInterval       = time.Minute
+	defaultConcurrency = 4
 )
 
 var (
-	ErrNoDataFound        = errors.New("no data found")
-	errMaxRetriesExceeded = errors.New("max retries exceeded")
-	errUnauthorized       = errors.New("no sessionid")
+	ErrNoDataFound  = errors.New("no data found")
+	errUnauthorized = errors.New("no sessionid")
 )
 
-type Session struct {
-	ID string
-}
-
 type SessionStorage interface {
 	LoadSession(ctx context.Context, phone string) (*Session, error)
 	UpdateSession(ctx context.Context, phone string, session *Session) error
@@ -42,20 +39,70 @@ type Credential struct {
 
 type ClientParams struct {
 	Clock          based.Clock    `validate:"required"`
-	Credential     Credential     `validate:"required"`
 	SessionStorage SessionStorage `validate:"required"`
 
-	AuthFlow  AuthFlow
-	Transport http.RoundTripper
+	// Phone – номер телефона. Если не задан, используется Credential.Phone.
+	Phone string
+
+	// Credentials предоставляет пароль только в момент авторизации.
+	// Если не задан, используются статические учетные данные Credential.
+	Credentials CredentialProvider
+
+	// Credential – статические учетные данные. Пароль хранится в памяти все время работы клиента.
+	Credential Credential
+
+	AuthFlow    AuthFlow
+	Transport   http.RoundTripper
+	BaseURL     string `validate:"omitempty,url"`
+	RetryPolicy RetryPolicy
+
+	// RateLimits задает ограничители частоты запросов. По умолчанию используется DefaultRateLimits.
+	RateLimits RateLimits
+
+	// AuthObserver получает события авторизации.
+	AuthObserver AuthObserver
+
+	// Metrics получает сведения о выполнении запросов.
+	Metrics MetricsObserver
+
+	// TracerProvider используется для создания span OpenTelemetry для каждого запроса к API.
+	// По умолчанию трассировка не ведется.
+	TracerProvider trace.TracerProvider
+
+	// Logger – журнал клиента. Идентификаторы сессий, пароли и номера телефонов в записях скрываются.
+	// По умолчанию журнал не ведется.
+	Logger *slog.Logger
+
+	// SessionIdleTimeout используется для оценки времени истечения сессии в SessionInfo. По умолчанию 30 минут.
+	SessionIdleTimeout time.Duration `validate:"gte=0"`
+
+	// Concurrency – максимальное количество одновременно выполняемых HTTP-запросов. По умолчанию 4.
+	Concurrency int `validate:"gte=0"`
+
+	// Middleware оборачивают каждую попытку выполнения запроса (см. Middleware).
+	Middleware []Middleware
 }
 
 type Client struct {
-	httpClient   *http.Client
-	authFlow     AuthFlow
-	credential   Credential
-	session      *based.WriteThroughCached[*Session]
-	rateLimiters map[string]based.Locker
-	mu           based.RWMutex
+	clock              based.Clock
+	baseURL            string
+	httpClient         *http.Client
+	authFlow           AuthFlow
+	retryPolicy        RetryPolicy
+	phone              string
+	credentials        CredentialProvider
+	session            *based.WriteThroughCached[*Session]
+	rateLimits         RateLimits
+	sessionIdleTimeout time.Duration
+	authObserver       AuthObserver
+	log                *slog.Logger
+	metrics            MetricsObserver
+	tracer             trace.Tracer
+	slots              based.Locker
+	handler            ExchangeHandler
+
+	// mu защищает получение и замену сессии, но не выполнение запросов.
+	mu based.RWMutex
 }
 
 func NewClient(params ClientParams) (*Client, error) {
@@ -68,37 +115,85 @@ func NewClient(params ClientParams) (*Client, error) {
 		authFlow = ApiAuthFlow
 	}
 
-	return &Client{
+	retryPolicy := params.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = DefaultRetryPolicy
+	}
+
+	rateLimits := params.RateLimits
+	if rateLimits == nil {
+		rateLimits = DefaultRateLimits(params.Clock)
+	}
+
+	phone := params.Phone
+	if phone == "" {
+		phone = params.Credential.Phone
+	}
+
+	if phone == "" {
+		return nil, errors.New("phone is required")
+	}
+
+	credentials := params.Credentials
+	if credentials == nil {
+		credentials = StaticCredentials(Credential{Phone: phone, Password: params.Credential.Password})
+	}
+
+	sessionIdleTimeout := params.SessionIdleTimeout
+	if sessionIdleTimeout == 0 {
+		sessionIdleTimeout = defaultSessionIdleTimeout
+	}
+
+	log := discardLogger()
+	if params.Logger != nil {
+		log = slog.New(redactingHandler{Handler: params.Logger.Handler()})
+	}
+
+	base := params.BaseURL
+	if base == "" {
+		base = baseURL
+	}
+
+	concurrency := params.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultConcurrency
+	}
+
+	c := &Client{
+		clock:   params.Clock,
+		baseURL: strings.TrimSuffix(base, "/"),
 		httpClient: &http.Client{
 			Transport: params.Transport,
 		},
-		authFlow:   authFlow,
-		credential: params.Credential,
+		authFlow:    authFlow,
+		retryPolicy: retryPolicy,
+		phone:       phone,
+		credentials: credentials,
 		session: based.NewWriteThroughCached(
 			based.WriteThroughCacheStorageFunc[string, *Session]{
 				LoadFn:   params.SessionStorage.LoadSession,
 				UpdateFn: params.SessionStorage.UpdateSession,
 			},
-			params.Credential.Phone,
+			phone,
 		),
-		rateLimiters: map[string]based.Locker{
-			shoppingReceiptPath: based.Lockers{
-				based.Semaphore(params.Clock, 25, 75*time.Second),
-				based.Semaphore(params.Clock, 75, 11*time.Minute),
-			},
-		},
-	}, nil
+		rateLimits:         rateLimits,
+		sessionIdleTimeout: sessionIdleTimeout,
+		authObserver:       params.AuthObserver,
+		log:                log.With(slog.String("phone", phone)),
+		metrics:            params.Metrics,
+		tracer:             newTracer(params.TracerProvider),
+		slots:              based.Semaphore(params.Clock, concurrency, 0),
+	}
+
+	c.handler = c.newExchangeHandler(params.Middleware)
+	return c, nil
 }
 
 func (c *Client) Ping(ctx context.Context) {
-	ticker := time.NewTicker(pingInterval)
-	defer ticker.Stop()
 	for {
 		_ = c.ping(ctx)
-		select {
-		case <-ctx.Done():
+		if err := sleep(ctx, c.clock, pingInterval); err != nil {
 			return
-		case <-ticker.C:
 		}
 	}
 }
@@ -139,6 +234,16 @@ func (c *Client) Operations(ctx context.Context, in *OperationsIn) (OperationsOu
 	return resp.Payload, nil
 }
 
+// OperationsExact работает так же, как Operations, но возвращает суммы операций в виде Decimal (см. ExactOperation).
+func (c *Client) OperationsExact(ctx context.Context, in *OperationsIn) ([]ExactOperation, error) {
+	out, err := Call(ctx, c, commonEndpoint[[]ExactOperation](in), in)
+	if err != nil {
+		return nil, err
+	}
+
+	return *out, nil
+}
+
 func (c *Client) ShoppingReceipt(ctx context.Context, in *ShoppingReceiptIn) (*ShoppingReceiptOut, error) {
 	resp, err := executeCommon(ctx, c, in)
 	if err != nil {
@@ -148,6 +253,11 @@ func (c *Client) ShoppingReceipt(ctx context.Context, in *ShoppingReceiptIn) (*S
 	return &resp.Payload, nil
 }
 
+// ShoppingReceiptExact работает так же, как ShoppingReceipt, но возвращает суммы чека в виде Decimal (см. ExactReceipt).
+func (c *Client) ShoppingReceiptExact(ctx context.Context, in *ShoppingReceiptIn) (*ExactShoppingReceiptOut, error) {
+	return Call(ctx, c, commonEndpoint[ExactShoppingReceiptOut](in), in)
+}
+
 func (c *Client) ClientOfferEssences(ctx context.Context) (ClientOfferEssencesOut, error) {
 	resp, err := executeCommon(ctx, c, clientOfferEssencesIn{})
 	if err != nil {
@@ -169,6 +279,16 @@ func (c *Client) InvestOperations(ctx context.Context, in *InvestOperationsIn) (
 	return executeInvest(ctx, c, in)
 }
 
+// InvestOperationsExact работает так же, как InvestOperations, но возвращает суммы операций в виде Decimal
+// (см. ExactInvestOperation).
+func (c *Client) InvestOperationsExact(ctx context.Context, in *InvestOperationsIn) (*ExactInvestOperationsOut, error) {
+	return Call(ctx, c, Endpoint[*InvestOperationsIn, ExactInvestOperationsOut]{
+		Path:   in.path(),
+		Auth:   AuthForce,
+		Invest: true,
+	}, in)
+}
+
 func (c *Client) InvestCandles(ctx context.Context, in *InvestCandlesIn) (*InvestCandlesOut, error) {
 	resp, err := executeCommon(ctx, c, in)
 	if err != nil {
@@ -178,15 +298,47 @@ func (c *Client) InvestCandles(ctx context.Context, in *InvestCandlesIn) (*Inves
 	return &resp.Payload, nil
 }
 
+func (c *Client) apiURL(path string) string {
+	return c.baseURL + "/api" + path
+}
+
 func (c *Client) rateLimiter(path string) based.Locker {
-	if rateLimiter, ok := c.rateLimiters[path]; ok {
-		return rateLimiter
+	return c.rateLimits.get(path)
+}
+
+// roundTrip выполняет HTTP-запрос и читает тело ответа.
+// Количество одновременно выполняемых запросов ограничено ClientParams.Concurrency.
+func (c *Client) roundTrip(ctx context.Context, httpReq *http.Request) (*http.Response, []byte, error) {
+	ctx, cancel := c.slots.Lock(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "execute request")
+	}
+
+	if httpResp.Body == nil {
+		return nil, nil, errors.New(httpResp.Status)
 	}
 
-	return based.Unlocker
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read response body")
+	}
+
+	return httpResp, body, nil
 }
 
 func (c *Client) getSessionID(ctx context.Context) (string, error) {
+	if session := getSession(ctx); session != nil {
+		return session.ID, nil
+	}
+
 	session, err := c.session.Get(ctx)
 	if err != nil {
 		return "", errors.Wrap(err, "get sessionid")
@@ -199,15 +351,32 @@ func (c *Client) getSessionID(ctx context.Context) (string, error) {
 	return session.ID, nil
 }
 
+// ensureSessionID возвращает текущую сессию, либо выполняет авторизацию, если сессии нет.
+// Одновременные запросы без сессии ожидают одну общую авторизацию.
 func (c *Client) ensureSessionID(ctx context.Context) (string, error) {
-	var err error
+	if session := getSession(ctx); session != nil {
+		return session.ID, nil
+	}
 
-	session := getSession(ctx)
-	if session == nil {
-		session, err = c.session.Get(ctx)
-		if err != nil {
-			return "", err
-		}
+	session, err := c.session.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if session != nil {
+		return session.ID, nil
+	}
+
+	ctx, cancel := c.mu.Lock(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// another request may have authorized while we were waiting for the lock
+	session, err = c.session.Get(ctx)
+	if err != nil {
+		return "", err
 	}
 
 	if session == nil {
@@ -223,183 +392,138 @@ func (c *Client) ensureSessionID(ctx context.Context) (string, error) {
 	return session.ID, nil
 }
 
-func (c *Client) resetSessionID(ctx context.Context) error {
+// invalidateSession сбрасывает сессию id, если она еще не была заменена другим запросом.
+func (c *Client) invalidateSession(ctx context.Context, id, reason string) error {
+	ctx, cancel := c.mu.Lock(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	session, err := c.session.Get(ctx)
+	if err != nil || session == nil || session.ID != id {
+		return err
+	}
+
+	c.observe(ctx, AuthSessionInvalidated, reason, nil)
 	return c.session.Update(ctx, nil)
 }
 
-func (c *Client) authorize(ctx context.Context) (*Session, error) {
+func (c *Client) authorize(ctx context.Context) (_ *Session, err error) {
 	authorizer := getAuthorizer(ctx)
 	if authorizer == nil {
 		return nil, errors.New("authorizer is required, but not set")
 	}
 
-	return c.authFlow.authorize(ctx, c, authorizer)
+	ctx, end := c.startChildSpan(ctx, "tbank.authorize", attribute.String("tbank.auth_flow", authFlowName(c.authFlow)))
+	defer func() { end(err) }()
+
+	c.observe(ctx, AuthStarted, "", nil)
+	session, err := c.authFlow.authorize(ctx, c, observedAuthorizer{Authorizer: authorizer, client: c})
+	if err != nil {
+		c.observe(ctx, AuthFailed, "", err)
+		return nil, err
+	}
+
+	c.observe(ctx, AuthSucceeded, "", nil)
+
+	now := c.clock.Now()
+	session.CreatedAt, session.ValidatedAt = now, now
+	session.AuthFlow = authFlowName(c.authFlow)
+	return session, nil
+}
+
+// password запрашивает пароль у CredentialProvider непосредственно перед использованием.
+func (c *Client) password(ctx context.Context) (string, error) {
+	credential, err := c.credentials.GetCredential(ctx, c.phone)
+	if err != nil {
+		return "", errors.Wrap(err, "get credential")
+	}
+
+	return credential.Password, nil
 }
 
 func (c *Client) ping(ctx context.Context) error {
-	ctx, cancel := c.mu.Lock(ctx)
-	defer cancel()
-	if err := ctx.Err(); err != nil {
-		return err
+	session := getSession(ctx)
+	if session == nil {
+		var err error
+		if session, err = c.session.Get(ctx); err != nil {
+			return errors.Wrap(err, "get sessionid")
+		}
+
+		if session == nil {
+			return errUnauthorized
+		}
 	}
 
-	out, err := executeCommon(ctx, c, pingIn{})
+	out, err := executeCommon(withSession(ctx, session), c, pingIn{})
 	if err != nil {
 		return errors.Wrap(err, "ping")
 	}
 
 	if out.Payload.AccessLevel != "CLIENT" {
-		if err := c.resetSessionID(ctx); err != nil {
+		if err := c.invalidateSession(ctx, session.ID, "access level "+out.Payload.AccessLevel); err != nil {
 			return errors.Wrap(err, "reset sessionid")
 		}
 
 		return errUnauthorized
 	}
 
-	return nil
+	return c.touchSession(ctx, session.ID, out.Payload.AccessLevel)
 }
 
-func executeInvest[R any](ctx context.Context, c *Client, in investExchange[R]) (*R, error) {
-	ctx, cancel := c.rateLimiter(in.path()).Lock(ctx)
+// touchSession сохраняет время последней успешной проверки сессии id.
+func (c *Client) touchSession(ctx context.Context, id, accessLevel string) error {
+	ctx, cancel := c.mu.Lock(ctx)
 	defer cancel()
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return err
 	}
 
-	var sessionID string
-	if in.auth() {
-		ctx, cancel = c.mu.Lock(ctx)
-		defer cancel()
-		if err := ctx.Err(); err != nil {
-			return nil, err
-		}
-
-		var err error
-		sessionID, err = c.ensureSessionID(ctx)
-		if err != nil {
-			return nil, errors.Wrap(err, "ensure sessionid")
-		}
+	session, err := c.session.Get(ctx)
+	if err != nil || session == nil || session.ID != id {
+		return err
 	}
 
-	urlQuery, err := query.Values(in)
+	validated := *session
+	validated.AccessLevel = accessLevel
+	validated.ValidatedAt = c.clock.Now()
+	return errors.Wrap(c.session.Update(ctx, &validated), "update session")
+}
+
+func executeInvest[R any](ctx context.Context, c *Client, in investExchange[R]) (*R, error) {
+	values, err := query.Values(in)
 	if err != nil {
 		return nil, errors.Wrap(err, "encode url query")
 	}
 
-	if sessionID != "" {
-		urlQuery.Set("sessionId", sessionID)
-	}
-
-	httpReq, err := http.NewRequest(http.MethodGet, baseApiURL+in.path(), nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "create http request")
+	auth := AuthNone
+	if in.auth() {
+		auth = AuthForce
 	}
 
-	httpReq.URL.RawQuery = urlQuery.Encode()
-	httpReq.Header.Set("X-App-Name", "invest")
-	httpReq.Header.Set("X-App-Version", "1.328.0")
+	resp, err := c.handler.Exchange(ctx, &Exchange{
+		Path:   in.path(),
+		Method: http.MethodGet,
+		Auth:   auth,
+		Invest: true,
+		Values: values,
+	})
 
-	httpResp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, errors.Wrap(err, "execute request")
-	}
-
-	if httpResp.Body == nil {
-		return nil, errors.New(httpResp.Status)
-	}
-
-	defer httpResp.Body.Close()
-
-	switch {
-	case httpResp.StatusCode == http.StatusOK:
-		var resp R
-		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
-			return nil, errors.Wrap(err, "unmarshal response body")
-		}
-
-		return &resp, nil
-
-	case httpResp.StatusCode >= 400 && httpResp.StatusCode < 600:
-		var investErr investError
-		if body, err := io.ReadAll(httpResp.Body); err != nil {
-			return nil, errors.New(httpResp.Status)
-		} else if err := json.Unmarshal(body, &investErr); err != nil {
-			return nil, errors.New(ellipsis(body))
-		} else {
-			if investErr.ErrorCode == "404" || investErr.ErrorCode == "Forbidden" {
-				// this may be due to expired sessionid, try to check it
-				if err := c.ping(ctx); errors.Is(err, errUnauthorized) {
-					retry := &retryStrategy{
-						timeout:    constantRetryTimeout(0),
-						maxRetries: 1,
-					}
-
-					ctx, err := retry.do(ctx)
-					if err != nil {
-						return nil, investErr
-					}
-
-					if _, err := c.authorize(ctx); err != nil {
-						return nil, errors.Wrap(err, "authorize")
-					}
-
-					return executeInvest(ctx, c, in)
-				}
-			}
-
-			return nil, investErr
-		}
-
-	default:
-		_, _ = io.Copy(io.Discard, httpResp.Body)
-		return nil, errors.New(httpResp.Status)
+		return nil, err
 	}
-}
 
-func ellipsis(data []byte) string {
-	str := string(data)
-	if len(str) > 200 {
-		return str + "..."
+	var out R
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		return nil, errors.Wrap(err, "unmarshal response body")
 	}
 
-	return str
+	return &out, nil
 }
 
 func executeCommon[R any](ctx context.Context, c *Client, in commonExchange[R]) (*commonResponse[R], error) {
-	ctx, cancel := c.rateLimiter(in.path()).Lock(ctx)
-	defer cancel()
-	if err := ctx.Err(); err != nil {
-		return nil, err
-	}
-
-	var sessionID string
-	if in.auth() != none {
-		var (
-			cancel context.CancelFunc
-			err    error
-		)
-
-		ctx, cancel = c.mu.Lock(ctx)
-		defer cancel()
-		if err := ctx.Err(); err != nil {
-			return nil, err
-		}
-
-		switch in.auth() {
-		case force:
-			sessionID, err = c.ensureSessionID(ctx)
-		case check:
-			sessionID, err = c.getSessionID(ctx)
-		default:
-			return nil, errors.Errorf("unsupported auth %v", in.auth())
-		}
-
-		if err != nil {
-			return nil, errors.Wrap(err, "get sessionid")
-		}
-	}
-
-	reqBody, err := query.Values(in)
+	values, err := query.Values(in)
 	if err != nil {
 		return nil, errors.Wrap(err, "encode form values")
 	}
@@ -409,96 +533,22 @@ func executeCommon[R any](ctx context.Context, c *Client, in commonExchange[R])
 		method = http.MethodGet
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, method, baseApiURL+in.path(), strings.NewReader(reqBody.Encode()))
-	if err != nil {
-		return nil, errors.Wrap(err, "create request")
-	}
-
-	urlQuery := make(url.Values)
-	urlQuery.Set("origin", "web,ib5,platform")
-	if sessionID != "" {
-		urlQuery.Set("sessionid", sessionID)
-	}
+	resp, err := c.handler.Exchange(ctx, &Exchange{
+		Path:         in.path(),
+		Method:       method,
+		Auth:         in.auth(),
+		Values:       values,
+		ExpectedCode: in.exprc(),
+	})
 
-	httpReq.URL.RawQuery = urlQuery.Encode()
-	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	httpResp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, errors.Wrap(err, "execute request")
-	}
-
-	if httpResp.Body == nil {
-		return nil, errors.New(httpResp.Status)
-	}
-
-	defer httpResp.Body.Close()
-
-	var (
-		respErr error
-		retry   *retryStrategy
-	)
-
-	if httpResp.StatusCode != http.StatusOK {
-		if body, err := io.ReadAll(httpResp.Body); err != nil {
-			respErr = errors.New(httpResp.Status)
-		} else {
-			respErr = errors.New(ellipsis(body))
-		}
-
-		retry = &retryStrategy{
-			timeout:    exponentialRetryTimeout(time.Second, 2, 0.5),
-			maxRetries: -1,
-		}
-	} else {
-		var resp commonResponse[R]
-		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
-			return nil, errors.Wrap(err, "decode response body")
-		}
-
-		if in.exprc() == resp.ResultCode {
-			return &resp, nil
-		}
-
-		respErr = resultCodeError{
-			actual:   resp.ResultCode,
-			expected: in.exprc(),
-			message:  resp.ErrorMessage,
-		}
-
-		switch resp.ResultCode {
-		case "NO_DATA_FOUND":
-			return nil, ErrNoDataFound
-
-		case "REQUEST_RATE_LIMIT_EXCEEDED":
-			retry = &retryStrategy{
-				timeout:    exponentialRetryTimeout(time.Minute, 2, 0.2),
-				maxRetries: 5,
-			}
-
-		case "INSUFFICIENT_PRIVILEGES":
-			if _, err := c.authorize(ctx); err != nil {
-				return nil, errors.Wrap(err, "authorize")
-			}
-
-			retry = &retryStrategy{
-				timeout:    constantRetryTimeout(0),
-				maxRetries: 1,
-			}
-		}
+		return nil, err
 	}
 
-	if retry != nil {
-		ctx, retryErr := retry.do(ctx)
-		switch {
-		case errors.Is(retryErr, errMaxRetriesExceeded):
-			// fallthrough
-		case retryErr != nil:
-			return nil, retryErr
-		default:
-			return executeCommon(ctx, c, in)
-		}
+	var out commonResponse[R]
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		return nil, errors.Wrap(err, "decode response body")
 	}
 
-	return nil, respErr
+	return &out, nil
 }
@@ -0,0 +1,240 @@
+package tinkoff_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+func newTestClient(t *testing.T, server *tinkofftest.Server, params tinkoff.ClientParams) *tinkoff.Client {
+	t.Helper()
+
+	if params.Clock == nil {
+		params.Clock = based.StandardClock
+	}
+
+	if params.SessionStorage == nil {
+		params.SessionStorage = new(tinkofftest.SessionStorage)
+	}
+
+	if params.RetryPolicy == nil {
+		params.RetryPolicy = tinkoff.NoRetryPolicy
+	}
+
+	params.Credential = tinkoff.Credential{Phone: server.Phone, Password: server.Password}
+	params.BaseURL = server.URL
+
+	client, err := tinkoff.NewClient(params)
+	require.NoError(t, err)
+	return client
+}
+
+func testContext(t *testing.T, server *tinkofftest.Server) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return tinkoff.WithAuthorizer(ctx, server.Authorizer())
+}
+
+func TestClient_Common(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	server.AddAccounts(tinkoff.Account{Id: "1"}, tinkoff.Account{Id: "2"})
+	server.AddOperations("1",
+		tinkoff.Operation{Id: "a", Account: "1", OperationTime: tinkoff.Milliseconds(now.Add(-2 * time.Hour))},
+		tinkoff.Operation{
+			Id:            "b",
+			Account:       "1",
+			OperationTime: tinkoff.Milliseconds(now.Add(-time.Hour)),
+			Amount:        tinkoff.MoneyAmount{Currency: tinkoff.Currency{Name: "RUB"}, Value: 0.1},
+		},
+		tinkoff.Operation{Id: "c", Account: "1", OperationTime: tinkoff.Milliseconds(now.Add(-48 * time.Hour))})
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{})
+
+	accounts, err := client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "1", accounts[0].Id)
+	assert.Equal(t, "2", accounts[1].Id)
+
+	end := now
+	operations, err := client.Operations(ctx, &tinkoff.OperationsIn{
+		Account: "1",
+		Start:   now.Add(-24 * time.Hour),
+		End:     &end,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+	assert.Equal(t, "b", operations[0].Id)
+	assert.Equal(t, "a", operations[1].Id)
+
+	exact, err := client.OperationsExact(ctx, &tinkoff.OperationsIn{
+		Account: "1",
+		Start:   now.Add(-24 * time.Hour),
+		End:     &end,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, exact, 2)
+	assert.Equal(t, "b", exact[0].Id)
+	assert.Equal(t, tinkoff.Money{Value: tinkoff.NewDecimal(1, 1), Currency: "RUB"}, exact[0].Amount.Money())
+
+	assert.Equal(t, 1, server.Requests("/common/v1/session"))
+	assert.Equal(t, 1, server.Requests("/common/v1/accounts_light_ib"))
+	assert.Equal(t, 2, server.Requests("/common/v1/operations"))
+}
+
+func TestClient_InvestOperationsCursor(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	operations := make([]tinkoff.InvestOperation, 7)
+	for i := range operations {
+		operations[i] = tinkoff.InvestOperation{
+			BrokerAccountId: "broker",
+			InternalId:      strconv.Itoa(i),
+			Date:            tinkoff.DateTimeMilliOffset(now.Add(-time.Duration(i) * time.Hour)),
+			Type:            "Buy",
+		}
+	}
+
+	server.AddInvestOperations(operations...)
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{})
+
+	result, err := client.InvestOperationsAll(ctx, &tinkoff.InvestOperationsIn{
+		From:  now.Add(-24 * time.Hour),
+		To:    now,
+		Limit: 3,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, result, len(operations))
+	for i, operation := range result {
+		assert.Equal(t, strconv.Itoa(i), operation.InternalId)
+	}
+
+	assert.Equal(t, 3, server.Requests("/invest-gw/ca-operations/api/v1/user/operations"))
+}
+
+func TestClient_ScriptedResultCodes(t *testing.T) {
+	t.Run("rate limit is retried", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		server.Script("/common/v1/accounts_light_ib", tinkoff.ResultCodeRateLimitExceeded, tinkoff.ResultCodeRateLimitExceeded)
+
+		var attempts []int
+		ctx := testContext(t, server)
+		client := newTestClient(t, server, tinkoff.ClientParams{
+			RetryPolicy: tinkoff.RetryPolicyFunc(func(attempt tinkoff.RetryAttempt) (time.Duration, bool) {
+				attempts = append(attempts, attempt.Attempt)
+				return time.Millisecond, tinkoff.IsRateLimited(attempt.Err)
+			}),
+		})
+
+		_, err := client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1}, attempts)
+		assert.Equal(t, 3, server.Requests("/common/v1/accounts_light_ib"))
+	})
+
+	t.Run("no data found", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		ctx := testContext(t, server)
+		client := newTestClient(t, server, tinkoff.ClientParams{})
+
+		_, err := client.ShoppingReceipt(ctx, &tinkoff.ShoppingReceiptIn{OperationId: "missing"})
+		require.Error(t, err)
+		assert.True(t, tinkoff.IsNoData(err))
+		assert.ErrorIs(t, err, tinkoff.ErrNoDataFound)
+	})
+
+	t.Run("insufficient privileges triggers reauthorization", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		ctx := testContext(t, server)
+		client := newTestClient(t, server, tinkoff.ClientParams{})
+
+		_, err := client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+
+		server.Script("/common/v1/accounts_light_ib", tinkoff.ResultCodeInsufficientPrivileges)
+		_, err = client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, server.Requests("/common/v1/session"))
+		assert.Equal(t, 3, server.Requests("/common/v1/accounts_light_ib"))
+	})
+
+	t.Run("unexpected code is returned", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		server.Script("/common/v1/accounts_light_ib", "INTERNAL_ERROR")
+
+		ctx := testContext(t, server)
+		client := newTestClient(t, server, tinkoff.ClientParams{})
+
+		_, err := client.AccountsLightIb(ctx)
+		var apiErr *tinkoff.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "INTERNAL_ERROR", apiErr.ResultCode)
+	})
+}
+
+func TestClient_ConcurrentRequests(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	const requests = 4
+	held, release := server.Hold("/common/v1/accounts_light_ib")
+	defer release()
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{Concurrency: requests})
+
+	errs := make(chan error, requests)
+	for range requests {
+		go func() {
+			_, err := client.AccountsLightIb(ctx)
+			errs <- err
+		}()
+	}
+
+	// all requests reach the server at the same time, sharing a single authorization
+	for range requests {
+		select {
+		case <-held:
+		case <-ctx.Done():
+			require.FailNow(t, "requests do not overlap")
+		}
+	}
+
+	release()
+	for range requests {
+		require.NoError(t, <-errs)
+	}
+
+	assert.Equal(t, 1, server.Requests("/common/v1/session"))
+	assert.Equal(t, requests, server.Requests("/common/v1/accounts_light_ib"))
+}
@@ -0,0 +1,72 @@
+package tinkoff_test
+
+import (
+	"sync"
+	"time"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+var _ tinkoff.TimerClock = (*fakeClock)(nil)
+
+// fakeClock – управляемые вручную часы. Таймеры, созданные через After, срабатывают только при вызове Advance.
+type fakeClock struct {
+	now     time.Time
+	timers  []fakeTimer
+	waiters chan struct{}
+	mu      sync.Mutex
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{
+		now:     now,
+		waiters: make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	timer := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.c <- c.now
+	} else {
+		c.timers = append(c.timers, timer)
+	}
+
+	c.mu.Unlock()
+	c.waiters <- struct{}{}
+	return timer.c
+}
+
+// Advance переводит часы на d вперед и запускает истекшие таймеры.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			timers = append(timers, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+
+	c.timers = timers
+}
+
+// WaitTimer блокируется, пока кто-нибудь не вызовет After.
+func (c *fakeClock) WaitTimer() {
+	<-c.waiters
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/jfk9w-go/based"
@@ -15,16 +14,36 @@ const (
 	shoppingReceiptPath = "/common/v1/shopping_receipt"
 )
 
-type auth int
+// AuthMode определяет, как запрос использует сессию.
+type AuthMode int
 
 const (
-	none auth = iota
-	check
-	force
+	// AuthNone – запрос выполняется без сессии.
+	AuthNone AuthMode = iota
+
+	// AuthCheck – запрос выполняется с текущей сессией. Если сессии нет, запрос завершается ошибкой.
+	AuthCheck
+
+	// AuthForce – запрос выполняется с текущей сессией. Если сессии нет или сервер ее отклонил,
+	// выполняется авторизация.
+	AuthForce
 )
 
+func (a AuthMode) String() string {
+	switch a {
+	case AuthNone:
+		return "none"
+	case AuthCheck:
+		return "check"
+	case AuthForce:
+		return "force"
+	default:
+		return "unknown"
+	}
+}
+
 type commonExchange[R any] interface {
-	auth() auth
+	auth() AuthMode
 	path() string
 	out() R
 	exprc() string
@@ -37,25 +56,6 @@ type commonResponse[R any] struct {
 	OperationTicket string `json:"operationTicket"`
 }
 
-type resultCodeError struct {
-	expected, actual string
-	message          string
-}
-
-func (e resultCodeError) Error() string {
-	var b strings.Builder
-	b.WriteString(e.actual)
-	b.WriteString(" != ")
-	b.WriteString(e.expected)
-	if e.message != "" {
-		b.WriteString(" (")
-		b.WriteString(e.message)
-		b.WriteString(")")
-	}
-
-	return b.String()
-}
-
 type Milliseconds time.Time
 
 func (ms Milliseconds) Time() time.Time {
@@ -128,7 +128,7 @@ func (dt *ReceiptDateTime) UnmarshalJSON(data []byte) error {
 
 type sessionIn struct{}
 
-func (in sessionIn) auth() auth          { return none }
+func (in sessionIn) auth() AuthMode      { return AuthNone }
 func (in sessionIn) path() string        { return "/common/v1/session" }
 func (in sessionIn) out() (_ sessionOut) { return }
 func (in sessionIn) exprc() string       { return "OK" }
@@ -137,7 +137,7 @@ type sessionOut = string
 
 type pingIn struct{}
 
-func (in pingIn) auth() auth       { return check }
+func (in pingIn) auth() AuthMode   { return AuthCheck }
 func (in pingIn) path() string     { return "/common/v1/ping" }
 func (in pingIn) out() (_ pingOut) { return }
 func (in pingIn) exprc() string    { return "OK" }
@@ -148,7 +148,7 @@ type pingOut struct {
 
 type signUpIn struct{}
 
-func (in signUpIn) auth() auth         { return check }
+func (in signUpIn) auth() AuthMode     { return AuthCheck }
 func (in signUpIn) path() string       { return "/common/v1/sign_up" }
 func (in signUpIn) out() (_ signUpOut) { return }
 
@@ -188,7 +188,7 @@ type confirmIn struct {
 	ConfirmationData       confirmationData `url:"confirmationData"`
 }
 
-func (in confirmIn) auth() auth          { return check }
+func (in confirmIn) auth() AuthMode      { return AuthCheck }
 func (in confirmIn) path() string        { return "/common/v1/confirm" }
 func (in confirmIn) out() (_ confirmOut) { return }
 func (in confirmIn) exprc() string       { return "OK" }
@@ -197,7 +197,7 @@ type confirmOut = json.RawMessage
 
 type levelUpIn struct{}
 
-func (in levelUpIn) auth() auth          { return check }
+func (in levelUpIn) auth() AuthMode      { return AuthCheck }
 func (in levelUpIn) path() string        { return "/common/v1/level_up" }
 func (in levelUpIn) out() (_ levelUpOut) { return }
 func (in levelUpIn) exprc() string       { return "OK" }
@@ -217,7 +217,7 @@ type MoneyAmount struct {
 
 type accountsLightIbIn struct{}
 
-func (in accountsLightIbIn) auth() auth                  { return force }
+func (in accountsLightIbIn) auth() AuthMode              { return AuthForce }
 func (in accountsLightIbIn) path() string                { return "/common/v1/accounts_light_ib" }
 func (in accountsLightIbIn) out() (_ AccountsLightIbOut) { return }
 func (in accountsLightIbIn) exprc() string               { return "OK" }
@@ -309,7 +309,7 @@ type AccountRequisitesIn struct {
 	Account string `url:"account" validate:"required"`
 }
 
-func (AccountRequisitesIn) auth() auth                     { return force }
+func (AccountRequisitesIn) auth() AuthMode                 { return AuthForce }
 func (AccountRequisitesIn) path() string                   { return "/common/v1/account_requisites" }
 func (AccountRequisitesIn) out() (_ *AccountRequisitesOut) { return }
 func (AccountRequisitesIn) exprc() string                  { return "OK" }
@@ -334,7 +334,7 @@ type StatementsIn struct {
 	ItemsOrder string `url:"itemsOrder,omitempty"`
 }
 
-func (StatementsIn) auth() auth             { return force }
+func (StatementsIn) auth() AuthMode         { return AuthForce }
 func (StatementsIn) path() string           { return "/common/v1/statements" }
 func (StatementsIn) out() (_ StatementsOut) { return }
 func (StatementsIn) exprc() string          { return "OK" }
@@ -387,7 +387,7 @@ type OperationsIn struct {
 	LoyaltyPaymentStatus   *string    `url:"loyaltyPaymentStatus,omitempty"`
 }
 
-func (in OperationsIn) auth() auth             { return force }
+func (in OperationsIn) auth() AuthMode         { return AuthForce }
 func (in OperationsIn) path() string           { return "/common/v1/operations" }
 func (in OperationsIn) out() (_ OperationsOut) { return }
 func (in OperationsIn) exprc() string          { return "OK" }
@@ -564,7 +564,7 @@ type ShoppingReceiptIn struct {
 	Account       *string    `url:"account,omitempty"`
 }
 
-func (in ShoppingReceiptIn) auth() auth                  { return force }
+func (in ShoppingReceiptIn) auth() AuthMode              { return AuthForce }
 func (in ShoppingReceiptIn) path() string                { return shoppingReceiptPath }
 func (in ShoppingReceiptIn) out() (_ ShoppingReceiptOut) { return }
 func (in ShoppingReceiptIn) exprc() string               { return "OK" }
@@ -618,7 +618,7 @@ type ShoppingReceiptOut struct {
 
 type clientOfferEssencesIn struct{}
 
-func (clientOfferEssencesIn) auth() auth                      { return force }
+func (clientOfferEssencesIn) auth() AuthMode                  { return AuthForce }
 func (clientOfferEssencesIn) path() string                    { return "/common/v1/client_offer_essences" }
 func (clientOfferEssencesIn) out() (_ ClientOfferEssencesOut) { return }
 func (clientOfferEssencesIn) exprc() string                   { return "OK" }
@@ -0,0 +1,128 @@
+package tinkoff_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+// newContextTestClient создает клиент с уже авторизованной сессией, который повторяет любые ошибки.
+// Часы клиента не идут сами по себе, поэтому ожидание может прервать только контекст.
+func newContextTestClient(t *testing.T, server *tinkofftest.Server, clock *fakeClock, params tinkoff.ClientParams) *tinkoff.Client {
+	t.Helper()
+
+	storage := new(tinkofftest.SessionStorage)
+	require.NoError(t, storage.UpdateSession(context.Background(), server.Phone, server.NewSession()))
+
+	params.Clock = clock
+	params.SessionStorage = storage
+	if params.RetryPolicy == nil {
+		params.RetryPolicy = tinkoff.RetryPolicyFunc(func(tinkoff.RetryAttempt) (time.Duration, bool) {
+			return time.Millisecond, true
+		})
+	}
+
+	return newTestClient(t, server, params)
+}
+
+func TestClient_CancelInFlight(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		path string
+		call func(ctx context.Context, client *tinkoff.Client) error
+	}{
+		{
+			name: "common",
+			path: "/common/v1/accounts_light_ib",
+			call: func(ctx context.Context, client *tinkoff.Client) error {
+				_, err := client.AccountsLightIb(ctx)
+				return err
+			},
+		},
+		{
+			name: "invest",
+			path: "/invest-gw/invest-portfolio/portfolios/accounts",
+			call: func(ctx context.Context, client *tinkoff.Client) error {
+				_, err := client.InvestAccounts(ctx, &tinkoff.InvestAccountsIn{Currency: "RUB"})
+				return err
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := tinkofftest.NewServer()
+			defer server.Close()
+
+			held, release := server.Hold(tc.path)
+			defer release()
+
+			client := newContextTestClient(t, server, newFakeClock(time.Now()), tinkoff.ClientParams{})
+			ctx, cancel := context.WithCancel(testContext(t, server))
+			defer cancel()
+
+			errs := make(chan error, 1)
+			go func() { errs <- tc.call(ctx, client) }()
+
+			<-held
+			cancel()
+			assert.ErrorIs(t, <-errs, context.Canceled)
+			assert.Zero(t, server.Requests(tc.path))
+		})
+	}
+}
+
+func TestClient_DeadlineInterruptsWait(t *testing.T) {
+	const path = "/common/v1/accounts_light_ib"
+
+	t.Run("retry backoff", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		server.Script(path, tinkoff.ResultCodeRateLimitExceeded)
+
+		clock := newFakeClock(time.Now())
+		client := newContextTestClient(t, server, clock, tinkoff.ClientParams{
+			RetryPolicy: tinkoff.RetryPolicyFunc(func(tinkoff.RetryAttempt) (time.Duration, bool) {
+				return time.Hour, true
+			}),
+		})
+
+		ctx, cancel := context.WithTimeout(testContext(t, server), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := client.AccountsLightIb(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, server.Requests(path))
+	})
+
+	t.Run("sliding window", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		clock := newFakeClock(time.Now())
+		client := newContextTestClient(t, server, clock, tinkoff.ClientParams{
+			RateLimits: tinkoff.RateLimits{path: tinkoff.SlidingWindow(clock, 1, time.Hour)},
+		})
+
+		_, err := client.AccountsLightIb(testContext(t, server))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(testContext(t, server), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = client.AccountsLightIb(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, server.Requests(path))
+
+		// the slot is released once the window passes
+		clock.Advance(time.Hour)
+		_, err = client.AccountsLightIb(testContext(t, server))
+		require.NoError(t, err)
+		assert.Equal(t, 2, server.Requests(path))
+	})
+}
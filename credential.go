@@ -0,0 +1,283 @@
+package tinkoff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrCredentialNotFound возвращается, если для номера телефона нет учетных данных.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// CredentialProvider возвращает учетные данные для номера телефона.
+// Клиент запрашивает учетные данные только во время авторизации и не хранит пароль,
+// поэтому смена пароля не требует перезапуска.
+type CredentialProvider interface {
+	GetCredential(ctx context.Context, phone string) (Credential, error)
+}
+
+// CredentialProviderFunc – функциональный адаптер для CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context, phone string) (Credential, error)
+
+func (fn CredentialProviderFunc) GetCredential(ctx context.Context, phone string) (Credential, error) {
+	return fn(ctx, phone)
+}
+
+// StaticCredentials возвращает заранее заданные учетные данные.
+func StaticCredentials(credentials ...Credential) CredentialProvider {
+	passwords := make(map[string]string, len(credentials))
+	for _, credential := range credentials {
+		passwords[credential.Phone] = credential.Password
+	}
+
+	return CredentialProviderFunc(func(_ context.Context, phone string) (Credential, error) {
+		return lookupCredential(passwords, phone)
+	})
+}
+
+// EnvCredentials читает пароль из переменной окружения.
+// Сначала проверяется переменная Var с суффиксом из цифр номера телефона (например, TBANK_PASSWORD_79999999999),
+// затем – сама переменная Var.
+type EnvCredentials struct {
+	Var string `validate:"required"`
+}
+
+func (p EnvCredentials) GetCredential(_ context.Context, phone string) (Credential, error) {
+	if err := based.Validate(p); err != nil {
+		return Credential{}, err
+	}
+
+	for _, name := range []string{p.Var + "_" + digits(phone), p.Var} {
+		if password, ok := os.LookupEnv(name); ok {
+			return Credential{Phone: phone, Password: password}, nil
+		}
+	}
+
+	return Credential{}, errors.Wrap(ErrCredentialNotFound, phone)
+}
+
+// FileCredentials читает пароли из JSON-файла вида {"+79999999999": "password"}.
+// Файл читается при каждом запросе.
+type FileCredentials struct {
+	Path string `validate:"required"`
+}
+
+func (p FileCredentials) GetCredential(_ context.Context, phone string) (Credential, error) {
+	if err := based.Validate(p); err != nil {
+		return Credential{}, err
+	}
+
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return Credential{}, errors.Wrap(err, "read credentials file")
+	}
+
+	var passwords map[string]string
+	if err := json.Unmarshal(data, &passwords); err != nil {
+		return Credential{}, errors.Wrap(err, "unmarshal credentials file")
+	}
+
+	return lookupCredential(passwords, phone)
+}
+
+const (
+	keyringSaltSize  = 16
+	keyringNonceSize = 24
+)
+
+// KeyringCredentials читает пароли из файла, зашифрованного NaCl secretbox
+// ключом, полученным из парольной фразы с помощью scrypt. Файл создается функцией WriteKeyring.
+// Passphrase вызывается при каждом запросе.
+type KeyringCredentials struct {
+	Path       string                                    `validate:"required"`
+	Passphrase func(ctx context.Context) ([]byte, error) `validate:"required"`
+}
+
+func (p KeyringCredentials) GetCredential(ctx context.Context, phone string) (Credential, error) {
+	if err := based.Validate(p); err != nil {
+		return Credential{}, err
+	}
+
+	passphrase, err := p.Passphrase(ctx)
+	if err != nil {
+		return Credential{}, errors.Wrap(err, "get passphrase")
+	}
+
+	passwords, err := ReadKeyring(p.Path, passphrase)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return lookupCredential(passwords, phone)
+}
+
+// ReadKeyring расшифровывает файл с паролями, созданный WriteKeyring.
+func ReadKeyring(path string, passphrase []byte) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read keyring")
+	}
+
+	if len(data) < keyringSaltSize+keyringNonceSize+secretbox.Overhead {
+		return nil, errors.New("keyring is too short")
+	}
+
+	salt, data := data[:keyringSaltSize], data[keyringSaltSize:]
+	var nonce [keyringNonceSize]byte
+	copy(nonce[:], data)
+	data = data[keyringNonceSize:]
+
+	key, err := keyringKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, ok := secretbox.Open(nil, data, &nonce, key)
+	if !ok {
+		return nil, errors.New("decrypt keyring: invalid passphrase or corrupted file")
+	}
+
+	var passwords map[string]string
+	if err := json.Unmarshal(plain, &passwords); err != nil {
+		return nil, errors.Wrap(err, "unmarshal keyring")
+	}
+
+	return passwords, nil
+}
+
+// WriteKeyring шифрует пароли (номер телефона -> пароль) и атомарно записывает их в файл с правами 0600.
+func WriteKeyring(path string, passphrase []byte, passwords map[string]string) error {
+	plain, err := json.Marshal(passwords)
+	if err != nil {
+		return errors.Wrap(err, "marshal keyring")
+	}
+
+	header := make([]byte, keyringSaltSize+keyringNonceSize)
+	if _, err := rand.Read(header); err != nil {
+		return errors.Wrap(err, "generate salt and nonce")
+	}
+
+	key, err := keyringKey(passphrase, header[:keyringSaltSize])
+	if err != nil {
+		return err
+	}
+
+	var nonce [keyringNonceSize]byte
+	copy(nonce[:], header[keyringSaltSize:])
+	data := secretbox.Seal(header, plain, &nonce, key)
+
+	return writeFileAtomic(path, data)
+}
+
+func keyringKey(passphrase, salt []byte) (*[32]byte, error) {
+	data, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "derive key")
+	}
+
+	var key [32]byte
+	copy(key[:], data)
+	return &key, nil
+}
+
+// CommandCredentials получает пароль от внешней программы по аналогии с git credential helpers.
+// Программе передается строка "phone=<номер>" на стандартный ввод.
+// В ответ она должна вывести строку "password=<пароль>" или только пароль.
+type CommandCredentials struct {
+	Command []string `validate:"required,min=1"`
+}
+
+func (p CommandCredentials) GetCredential(ctx context.Context, phone string) (Credential, error) {
+	if err := based.Validate(p); err != nil {
+		return Credential{}, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Stdin = strings.NewReader("phone=" + phone + "\n\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Credential{}, errors.Wrapf(err, "run credential command: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	output := strings.TrimSpace(stdout.String())
+	password := ""
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "password="); ok {
+			password = value
+			break
+		}
+	}
+
+	if password == "" && !strings.Contains(output, "\n") {
+		password = output
+	}
+
+	if password == "" {
+		return Credential{}, errors.Wrap(ErrCredentialNotFound, phone)
+	}
+
+	return Credential{Phone: phone, Password: password}, nil
+}
+
+func lookupCredential(passwords map[string]string, phone string) (Credential, error) {
+	password, ok := passwords[phone]
+	if !ok {
+		return Credential{}, errors.Wrap(ErrCredentialNotFound, phone)
+	}
+
+	return Credential{Phone: phone, Password: password}, nil
+}
+
+func digits(str string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+
+		return -1
+	}, str)
+}
+
+// writeFileAtomic записывает файл через временный файл в той же директории.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "create temp file")
+	}
+
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0o600); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "chmod temp file")
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "write temp file")
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "sync temp file")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "close temp file")
+	}
+
+	return errors.Wrap(os.Rename(file.Name(), path), "rename temp file")
+}
@@ -0,0 +1,56 @@
+package tinkoff_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+const testPhone = "+79999999999"
+
+func TestCredentialProviders(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "credentials.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"+79999999999": "file"}`), 0600))
+
+	keyring := filepath.Join(dir, "keyring")
+	require.NoError(t, tinkoff.WriteKeyring(keyring, []byte("secret"), map[string]string{testPhone: "keyring"}))
+	passphrase := func(context.Context) ([]byte, error) { return []byte("secret"), nil }
+
+	t.Setenv("TEST_TBANK_PASSWORD_79999999999", "env")
+
+	for _, tc := range []struct {
+		name     string
+		provider tinkoff.CredentialProvider
+		password string
+		err      bool
+	}{
+		{name: "env", provider: tinkoff.EnvCredentials{Var: "TEST_TBANK_PASSWORD"}, password: "env"},
+		{name: "env without var", provider: tinkoff.EnvCredentials{}, err: true},
+		{name: "file", provider: tinkoff.FileCredentials{Path: file}, password: "file"},
+		{name: "file without path", provider: tinkoff.FileCredentials{}, err: true},
+		{name: "keyring", provider: tinkoff.KeyringCredentials{Path: keyring, Passphrase: passphrase}, password: "keyring"},
+		{name: "keyring without path", provider: tinkoff.KeyringCredentials{Passphrase: passphrase}, err: true},
+		{name: "keyring without passphrase", provider: tinkoff.KeyringCredentials{Path: keyring}, err: true},
+		{name: "command", provider: tinkoff.CommandCredentials{Command: []string{"sh", "-c", "echo password=command"}}, password: "command"},
+		{name: "command without command", provider: tinkoff.CommandCredentials{}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			credential, err := tc.provider.GetCredential(context.Background(), testPhone)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tinkoff.Credential{Phone: testPhone, Password: tc.password}, credential)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package tinkoff
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ResultCodeNoDataFound            = "NO_DATA_FOUND"
+	ResultCodeRateLimitExceeded      = "REQUEST_RATE_LIMIT_EXCEEDED"
+	ResultCodeInsufficientPrivileges = "INSUFFICIENT_PRIVILEGES"
+
+	investErrorCodeForbidden = "Forbidden"
+	investErrorCodeNotFound  = "404"
+)
+
+// APIError описывает ошибку, полученную от API.
+// Для /common/v1/* ошибкой считается код результата, отличный от ожидаемого, либо HTTP-статус, отличный от 200.
+// Для /invest-gw/* ResultCode содержит поле errorCode ответа.
+type APIError struct {
+	// ResultCode – код результата (resultCode или errorCode), если он был получен.
+	ResultCode string
+
+	// ExpectedCode – ожидаемый код результата. Пустой для /invest-gw/*.
+	ExpectedCode string
+
+	// Message – сообщение об ошибке от API.
+	Message string
+
+	HTTPStatus int
+	Path       string
+	Body       []byte
+
+	// RetryAfter – задержка из заголовка Retry-After, если он был в ответе.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	switch {
+	case e.ExpectedCode != "":
+		b.WriteString(e.ResultCode)
+		b.WriteString(" != ")
+		b.WriteString(e.ExpectedCode)
+		if e.Message != "" {
+			b.WriteString(" (")
+			b.WriteString(e.Message)
+			b.WriteString(")")
+		}
+
+	case e.ResultCode != "":
+		b.WriteString(e.Message)
+		b.WriteString(" (")
+		b.WriteString(e.ResultCode)
+		b.WriteString(")")
+
+	case len(e.Body) > 0:
+		b.WriteString(ellipsis(e.Body))
+
+	default:
+		b.WriteString(http.StatusText(e.HTTPStatus))
+	}
+
+	return b.String()
+}
+
+// Is позволяет проверять ошибку на ErrNoDataFound с помощью errors.Is.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNoDataFound && e.ResultCode == ResultCodeNoDataFound
+}
+
+// IsRateLimited проверяет, что запрос был отклонен из-за превышения лимита запросов.
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.ResultCode == ResultCodeRateLimitExceeded || apiErr.HTTPStatus == http.StatusTooManyRequests
+}
+
+// IsUnauthorized проверяет, что запрос был отклонен из-за отсутствия или недостаточного уровня сессии.
+func IsUnauthorized(err error) bool {
+	if errors.Is(err, errUnauthorized) {
+		return true
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch {
+	case apiErr.ResultCode == ResultCodeInsufficientPrivileges, apiErr.ResultCode == investErrorCodeForbidden:
+		return true
+	case apiErr.HTTPStatus == http.StatusUnauthorized, apiErr.HTTPStatus == http.StatusForbidden:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsNoData проверяет, что API не вернуло данных по запросу.
+func IsNoData(err error) bool {
+	return errors.Is(err, ErrNoDataFound)
+}
+
+const maxErrorBodyLength = 200
+
+// ellipsis обрезает тело ответа до maxErrorBodyLength байт, не разрывая многобайтовые символы.
+func ellipsis(data []byte) string {
+	str := string(data)
+	if len(str) <= maxErrorBodyLength {
+		return str
+	}
+
+	cut := 0
+	for i := range str {
+		if i > maxErrorBodyLength {
+			break
+		}
+
+		cut = i
+	}
+
+	return str[:cut] + "..."
+}
@@ -0,0 +1,28 @@
+package tinkoff
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEllipsis(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{name: "short", in: "ошибка", out: "ошибка"},
+		{name: "ascii", in: strings.Repeat("a", 250), out: strings.Repeat("a", 200) + "..."},
+		{name: "cyrillic", in: strings.Repeat("я", 150), out: strings.Repeat("я", 100) + "..."},
+		{name: "cyrillic with odd offset", in: "a" + strings.Repeat("я", 150), out: "a" + strings.Repeat("я", 99) + "..."},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := ellipsis([]byte(tc.in))
+			assert.Equal(t, tc.out, out)
+			assert.True(t, utf8.ValidString(out))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package tinkoff
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Типы Exact* повторяют типы ответов API, заменяя денежные значения на Decimal.
+// Числа из ответа разбираются по их десятичной записи, без промежуточного преобразования в float64.
+// Исходный тип встроен в Exact-тип и заполняется как обычно, поэтому остальные поля доступны без изменений,
+// а значения с плавающей точкой – через встроенное поле (например, ExactOperation.Operation.Amount).
+//
+// Точные значения возвращаются методами клиента с суффиксом Exact (например, Client.OperationsExact),
+// а также могут использоваться как Out в Endpoint.
+
+// ExactMoneyAmount – MoneyAmount с точным значением суммы.
+type ExactMoneyAmount struct {
+	Currency Currency `json:"currency"`
+	Value    Decimal  `json:"value"`
+}
+
+func (a ExactMoneyAmount) Money() Money {
+	return Money{Value: a.Value, Currency: a.Currency.Name}
+}
+
+// ExactInvestAmount – InvestAmount с точным значением суммы.
+type ExactInvestAmount struct {
+	Currency string  `json:"currency"`
+	Value    Decimal `json:"value"`
+}
+
+func (a ExactInvestAmount) Money() Money {
+	return Money{Value: a.Value, Currency: strings.ToUpper(a.Currency)}
+}
+
+// ExactOperation – Operation с точными суммами операции, суммой в валюте счета и кэшбэком.
+type ExactOperation struct {
+	Operation
+	Amount         ExactMoneyAmount `json:"amount"`
+	AccountAmount  ExactMoneyAmount `json:"accountAmount"`
+	CashbackAmount ExactMoneyAmount `json:"cashbackAmount"`
+}
+
+func (o *ExactOperation) UnmarshalJSON(data []byte) error {
+	type plain ExactOperation
+	return unmarshalExact(data, (*plain)(o), &o.Operation)
+}
+
+// ExactReceiptItem – ReceiptItem с точными ценой и стоимостью позиции.
+type ExactReceiptItem struct {
+	ReceiptItem
+	Price Decimal `json:"price"`
+	Sum   Decimal `json:"sum"`
+}
+
+func (i *ExactReceiptItem) UnmarshalJSON(data []byte) error {
+	type plain ExactReceiptItem
+	return unmarshalExact(data, (*plain)(i), &i.ReceiptItem)
+}
+
+// ExactReceipt – Receipt с точной суммой чека и позициями.
+type ExactReceipt struct {
+	Receipt
+	Items    []ExactReceiptItem `json:"items"`
+	TotalSum Decimal            `json:"totalSum"`
+}
+
+func (r *ExactReceipt) UnmarshalJSON(data []byte) error {
+	type plain ExactReceipt
+	return unmarshalExact(data, (*plain)(r), &r.Receipt)
+}
+
+type ExactShoppingReceiptOut struct {
+	ShoppingReceiptOut
+	Receipt ExactReceipt `json:"receipt"`
+}
+
+func (o *ExactShoppingReceiptOut) UnmarshalJSON(data []byte) error {
+	type plain ExactShoppingReceiptOut
+	return unmarshalExact(data, (*plain)(o), &o.ShoppingReceiptOut)
+}
+
+// ExactInvestOperation – InvestOperation с точными суммами платежа, цены, комиссии и дохода.
+type ExactInvestOperation struct {
+	InvestOperation
+	Payment    ExactInvestAmount  `json:"payment"`
+	Price      *ExactInvestAmount `json:"price,omitempty"`
+	Commission *ExactInvestAmount `json:"commission,omitempty"`
+	Yield      *ExactInvestAmount `json:"yield,omitempty"`
+}
+
+func (o *ExactInvestOperation) UnmarshalJSON(data []byte) error {
+	type plain ExactInvestOperation
+	return unmarshalExact(data, (*plain)(o), &o.InvestOperation)
+}
+
+type ExactInvestOperationsOut struct {
+	HasNext    bool                   `json:"hasNext"`
+	Items      []ExactInvestOperation `json:"items"`
+	NextCursor string                 `json:"nextCursor"`
+}
+
+// unmarshalExact декодирует data в exact, а затем во встроенное в него значение base,
+// поля которого с точными значениями скрыты полями exact.
+func unmarshalExact(data []byte, exact, base any) error {
+	if err := json.Unmarshal(data, exact); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, base)
+}
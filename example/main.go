@@ -3,12 +3,10 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -21,85 +19,6 @@ import (
 	tbank "github.com/jfk9w-go/tbank-api"
 )
 
-type jsonSessionStorage struct {
-	path string
-}
-
-func (s jsonSessionStorage) LoadSession(ctx context.Context, phone string) (*tbank.Session, error) {
-	file, err := s.open(os.O_RDONLY)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, nil
-		}
-
-		return nil, err
-	}
-
-	defer file.Close()
-	contents := make(map[string]tbank.Session)
-	if err := json.NewDecoder(file).Decode(&contents); err != nil {
-		return nil, errors.Wrap(err, "decode json")
-	}
-
-	if session, ok := contents[phone]; ok {
-		return &session, nil
-	}
-
-	return nil, nil
-}
-
-func (s jsonSessionStorage) UpdateSession(ctx context.Context, phone string, session *tbank.Session) error {
-	file, err := s.open(os.O_RDWR | os.O_CREATE)
-	if err != nil {
-		return err
-	}
-
-	stat, err := file.Stat()
-	if err != nil {
-		return errors.Wrap(err, "stat")
-	}
-
-	contents := make(map[string]tbank.Session)
-	if stat.Size() > 0 {
-		if err := json.NewDecoder(file).Decode(&contents); err != nil {
-			return errors.Wrap(err, "decode json")
-		}
-	}
-
-	if session != nil {
-		contents[phone] = *session
-	} else {
-		delete(contents, phone)
-	}
-
-	if err := file.Truncate(0); err != nil {
-		return errors.Wrap(err, "truncate file")
-	}
-
-	if _, err := file.Seek(0, 0); err != nil {
-		return errors.Wrap(err, "seek to the start of file")
-	}
-
-	if err := json.NewEncoder(file).Encode(&contents); err != nil {
-		return errors.Wrap(err, "encode json")
-	}
-
-	return nil
-}
-
-func (s jsonSessionStorage) open(flag int) (*os.File, error) {
-	if err := os.MkdirAll(filepath.Dir(s.path), os.ModeDir); err != nil {
-		return nil, errors.Wrap(err, "create parent directory")
-	}
-
-	file, err := os.OpenFile(s.path, flag, 0644)
-	if err != nil {
-		return nil, errors.Wrap(err, "open file")
-	}
-
-	return file, nil
-}
-
 type authorizer struct{}
 
 func (a authorizer) GetConfirmationCode(ctx context.Context, phone string) (string, error) {
@@ -143,7 +62,6 @@ func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 func main() {
 	var config struct {
 		Phone        string `env:"TBANK_PHONE,required"`
-		Password     string `env:"TBANK_PASSWORD,required"`
 		SessionsFile string `env:"TBANK_SESSIONS_FILE,required"`
 	}
 
@@ -155,12 +73,10 @@ func main() {
 	defer cancel()
 
 	client, err := tbank.NewClient(tbank.ClientParams{
-		Clock: based.StandardClock,
-		Credential: tbank.Credential{
-			Phone:    config.Phone,
-			Password: config.Password,
-		},
-		SessionStorage: jsonSessionStorage{path: config.SessionsFile},
+		Clock:          based.StandardClock,
+		Phone:          config.Phone,
+		Credentials:    tbank.EnvCredentials{Var: "TBANK_PASSWORD"},
+		SessionStorage: &tbank.FileSessionStorage{Path: config.SessionsFile},
 		Transport:      new(httpTransport),
 		AuthFlow:       new(tbank.SeleniumAuthFlow),
 	})
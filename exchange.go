@@ -0,0 +1,313 @@
+package tinkoff
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Exchange описывает запрос к API.
+type Exchange struct {
+	// Path – путь эндпоинта, например "/common/v1/operations".
+	Path string
+
+	// Method – HTTP-метод.
+	Method string
+
+	// Auth определяет, как запрос использует сессию.
+	Auth AuthMode
+
+	// Invest равно true для запросов к /invest-gw/*: параметры передаются в строке запроса,
+	// а ошибки возвращаются HTTP-статусом и полем errorCode в теле ответа.
+	// Для остальных запросов параметры передаются в теле формы, а ответ содержит поле resultCode.
+	Invest bool
+
+	// Values – параметры запроса.
+	Values url.Values
+
+	// ExpectedCode – ожидаемый resultCode. Не используется для /invest-gw/*.
+	ExpectedCode string
+}
+
+// ExchangeResponse – успешный ответ API.
+type ExchangeResponse struct {
+	HTTPStatus int
+	Header     http.Header
+	Body       []byte
+
+	// ResultCode – код результата (resultCode). Пустой для /invest-gw/*.
+	ResultCode string
+}
+
+// ExchangeHandler выполняет запрос к API.
+// Ошибки API возвращаются в виде *APIError.
+type ExchangeHandler interface {
+	Exchange(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error)
+}
+
+// ExchangeHandlerFunc – функциональный адаптер для ExchangeHandler.
+type ExchangeHandlerFunc func(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error)
+
+func (fn ExchangeHandlerFunc) Exchange(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error) {
+	return fn(ctx, exchange)
+}
+
+// Middleware оборачивает ExchangeHandler, добавляя к запросам общее поведение (кэширование, аудит, внедрение ошибок).
+//
+// Middleware из ClientParams.Middleware вызываются для каждой попытки выполнения запроса после трассировки,
+// повторов, ограничения частоты запросов и получения сессии, и непосредственно перед отправкой HTTP-запроса.
+// Поэтому ошибки, возвращенные middleware, обрабатываются клиентом так же, как ошибки API:
+// например, *APIError с кодом ResultCodeRateLimitExceeded приведет к повтору согласно RetryPolicy.
+type Middleware func(next ExchangeHandler) ExchangeHandler
+
+// chainMiddleware оборачивает handler в middleware. Первый middleware выполняется первым.
+func chainMiddleware(handler ExchangeHandler, middleware ...Middleware) ExchangeHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// newExchangeHandler собирает цепочку обработки запросов клиента.
+func (c *Client) newExchangeHandler(middleware []Middleware) ExchangeHandler {
+	builtin := []Middleware{
+		c.traceMiddleware,
+		c.retryMiddleware,
+		c.rateLimitMiddleware,
+		c.sessionMiddleware,
+	}
+
+	return chainMiddleware(ExchangeHandlerFunc(c.transport), append(builtin, middleware...)...)
+}
+
+func (c *Client) traceMiddleware(next ExchangeHandler) ExchangeHandler {
+	return ExchangeHandlerFunc(func(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error) {
+		ctx, span := c.startExchangeSpan(ctx, exchange.Path, exchange.Auth)
+		resp, err := next.Exchange(ctx, exchange)
+		endSpan(span, err)
+		return resp, err
+	})
+}
+
+func (c *Client) retryMiddleware(next ExchangeHandler) ExchangeHandler {
+	return ExchangeHandlerFunc(func(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error) {
+		for {
+			resp, err := next.Exchange(ctx, exchange)
+			if err == nil || IsNoData(err) {
+				return resp, err
+			}
+
+			var (
+				ok       bool
+				retryErr error
+			)
+
+			ctx, ok, retryErr = c.retry(ctx, exchange.Path, err)
+			switch {
+			case retryErr != nil:
+				return nil, retryErr
+			case !ok:
+				return nil, err
+			}
+		}
+	})
+}
+
+func (c *Client) rateLimitMiddleware(next ExchangeHandler) ExchangeHandler {
+	return ExchangeHandlerFunc(func(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error) {
+		ctx, cancel := c.waitRateLimit(ctx, exchange.Path)
+		defer cancel()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return next.Exchange(ctx, exchange)
+	})
+}
+
+type sessionIDKey struct{}
+
+// sessionMiddleware получает сессию для запроса и выполняет повторную авторизацию, если сервер ее отклонил.
+func (c *Client) sessionMiddleware(next ExchangeHandler) ExchangeHandler {
+	var handler ExchangeHandlerFunc
+	handler = func(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error) {
+		if exchange.Auth == AuthNone {
+			return next.Exchange(ctx, exchange)
+		}
+
+		sessionCtx, end := c.startChildSpan(ctx, "tbank.session.acquire")
+		sessionID, err := c.acquireSessionID(sessionCtx, exchange.Auth)
+		end(err)
+		if err != nil {
+			return nil, errors.Wrap(err, "get sessionid")
+		}
+
+		resp, err := next.Exchange(context.WithValue(ctx, sessionIDKey{}, sessionID), exchange)
+		reason, rejected := c.sessionRejected(ctx, exchange, err)
+		if !rejected {
+			return resp, err
+		}
+
+		ctx, ok, reauthErr := c.reauthorize(ctx, reason, sessionID)
+		switch {
+		case reauthErr != nil:
+			return nil, reauthErr
+		case !ok:
+			return nil, err
+		}
+
+		return handler(ctx, exchange)
+	}
+
+	return handler
+}
+
+func (c *Client) acquireSessionID(ctx context.Context, auth AuthMode) (string, error) {
+	switch auth {
+	case AuthForce:
+		return c.ensureSessionID(ctx)
+	case AuthCheck:
+		return c.getSessionID(ctx)
+	default:
+		return "", errors.Errorf("unsupported auth %v", auth)
+	}
+}
+
+// sessionRejected проверяет, что запрос был отклонен из-за недействительной сессии.
+// Возвращает причину инвалидации сессии, если о ней еще не было сообщено.
+func (c *Client) sessionRejected(ctx context.Context, exchange *Exchange, err error) (string, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return "", false
+	}
+
+	if !exchange.Invest {
+		// AuthCheck requests (ping and the authorization steps) are sent with a known session,
+		// so reauthorizing would start a nested authorization instead of retrying the request
+		return apiErr.ResultCode, exchange.Auth == AuthForce && apiErr.ResultCode == ResultCodeInsufficientPrivileges
+	}
+
+	if apiErr.ResultCode == investErrorCodeNotFound || apiErr.ResultCode == investErrorCodeForbidden {
+		// this may be due to expired sessionid, try to check it
+		return "", errors.Is(c.ping(ctx), errUnauthorized)
+	}
+
+	return "", false
+}
+
+// transport отправляет HTTP-запрос и разбирает ответ.
+func (c *Client) transport(ctx context.Context, exchange *Exchange) (*ExchangeResponse, error) {
+	httpReq, err := c.newHTTPRequest(ctx, exchange)
+	if err != nil {
+		return nil, errors.Wrap(err, "create http request")
+	}
+
+	start := c.clock.Now()
+	httpResp, body, err := c.roundTrip(ctx, httpReq)
+	if err != nil {
+		c.observeExchange(ctx, exchange.Path, start, 0, "", err)
+		return nil, err
+	}
+
+	resp := &ExchangeResponse{
+		HTTPStatus: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Body:       body,
+	}
+
+	apiErr := &APIError{
+		HTTPStatus: resp.HTTPStatus,
+		Path:       exchange.Path,
+		Body:       body,
+		RetryAfter: parseRetryAfter(resp.Header, c.clock.Now()),
+	}
+
+	switch {
+	case exchange.Invest && resp.HTTPStatus == http.StatusOK:
+		c.observeExchange(ctx, exchange.Path, start, resp.HTTPStatus, "", nil)
+		return resp, nil
+
+	case exchange.Invest && resp.HTTPStatus >= 400 && resp.HTTPStatus < 600:
+		var investErr struct {
+			ErrorMessage string `json:"errorMessage"`
+			ErrorCode    string `json:"errorCode"`
+		}
+
+		if err := json.Unmarshal(body, &investErr); err == nil {
+			apiErr.ResultCode = investErr.ErrorCode
+			apiErr.Message = investErr.ErrorMessage
+		}
+
+	case exchange.Invest:
+		apiErr.RetryAfter = 0
+
+	case resp.HTTPStatus == http.StatusOK:
+		var commonResp struct {
+			ResultCode   string `json:"resultCode"`
+			ErrorMessage string `json:"errorMessage"`
+		}
+
+		if err := json.Unmarshal(body, &commonResp); err != nil {
+			return nil, errors.Wrap(err, "decode response body")
+		}
+
+		resp.ResultCode = commonResp.ResultCode
+		c.observeExchange(ctx, exchange.Path, start, resp.HTTPStatus, resp.ResultCode, nil)
+		if resp.ResultCode == exchange.ExpectedCode {
+			return resp, nil
+		}
+
+		apiErr.ResultCode = resp.ResultCode
+		apiErr.ExpectedCode = exchange.ExpectedCode
+		apiErr.Message = commonResp.ErrorMessage
+		return nil, apiErr
+	}
+
+	c.observeExchange(ctx, exchange.Path, start, resp.HTTPStatus, apiErr.ResultCode, apiErr)
+	return nil, apiErr
+}
+
+func (c *Client) newHTTPRequest(ctx context.Context, exchange *Exchange) (*http.Request, error) {
+	sessionID, _ := ctx.Value(sessionIDKey{}).(string)
+	if exchange.Invest {
+		urlQuery := maps.Clone(exchange.Values)
+		if urlQuery == nil {
+			urlQuery = make(url.Values)
+		}
+
+		if sessionID != "" {
+			urlQuery.Set("sessionId", sessionID)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, exchange.Method, c.apiURL(exchange.Path), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		httpReq.URL.RawQuery = urlQuery.Encode()
+		httpReq.Header.Set("X-App-Name", "invest")
+		httpReq.Header.Set("X-App-Version", "1.328.0")
+		return httpReq, nil
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, exchange.Method, c.apiURL(exchange.Path), strings.NewReader(exchange.Values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	urlQuery := make(url.Values)
+	urlQuery.Set("origin", "web,ib5,platform")
+	if sessionID != "" {
+		urlQuery.Set("sessionid", sessionID)
+	}
+
+	httpReq.URL.RawQuery = urlQuery.Encode()
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return httpReq, nil
+}
@@ -0,0 +1,142 @@
+package tinkoff_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+func TestClient_Middleware(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	var calls []string
+	record := func(name string, fail func(attempt int) error) tinkoff.Middleware {
+		attempt := 0
+		return func(next tinkoff.ExchangeHandler) tinkoff.ExchangeHandler {
+			return tinkoff.ExchangeHandlerFunc(func(ctx context.Context, exchange *tinkoff.Exchange) (*tinkoff.ExchangeResponse, error) {
+				calls = append(calls, name+" "+exchange.Path)
+				attempt++
+				if err := fail(attempt); err != nil {
+					return nil, err
+				}
+
+				resp, err := next.Exchange(ctx, exchange)
+				calls = append(calls, name+" done")
+				return resp, err
+			})
+		}
+	}
+
+	pass := func(int) error { return nil }
+	newClient := func(storage tinkoff.SessionStorage, middleware ...tinkoff.Middleware) *tinkoff.Client {
+		calls = nil
+		return newTestClient(t, server, tinkoff.ClientParams{
+			SessionStorage: storage,
+			RetryPolicy: tinkoff.RetryPolicyFunc(func(attempt tinkoff.RetryAttempt) (time.Duration, bool) {
+				return 0, attempt.Attempt == 0 && tinkoff.IsRateLimited(attempt.Err)
+			}),
+			Middleware: middleware,
+		})
+	}
+
+	ctx := testContext(t, server)
+	storage := new(tinkofftest.SessionStorage)
+
+	t.Run("session is acquired first", func(t *testing.T) {
+		client := newClient(storage, record("first", pass))
+		_, err := client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+		require.Greater(t, len(calls), 2)
+		assert.Equal(t, "first /common/v1/session", calls[0])
+		assert.Equal(t, []string{"first /common/v1/accounts_light_ib", "first done"}, calls[len(calls)-2:])
+	})
+
+	t.Run("order", func(t *testing.T) {
+		client := newClient(storage, record("first", pass), record("second", pass))
+		_, err := client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"first /common/v1/accounts_light_ib",
+			"second /common/v1/accounts_light_ib",
+			"second done",
+			"first done",
+		}, calls)
+	})
+
+	t.Run("error short-circuits the chain", func(t *testing.T) {
+		requests := server.Requests("/common/v1/accounts_light_ib")
+		failed := errors.New("failed")
+		client := newClient(storage, record("first", func(int) error { return failed }), record("second", pass))
+		_, err := client.AccountsLightIb(ctx)
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, []string{"first /common/v1/accounts_light_ib"}, calls)
+		assert.Equal(t, requests, server.Requests("/common/v1/accounts_light_ib"))
+	})
+
+	t.Run("each attempt is wrapped", func(t *testing.T) {
+		client := newClient(storage, record("first", pass), record("second", func(attempt int) error {
+			if attempt == 1 {
+				return &tinkoff.APIError{ResultCode: tinkoff.ResultCodeRateLimitExceeded}
+			}
+
+			return nil
+		}))
+
+		_, err := client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"first /common/v1/accounts_light_ib",
+			"second /common/v1/accounts_light_ib",
+			"first done",
+			"first /common/v1/accounts_light_ib",
+			"second /common/v1/accounts_light_ib",
+			"second done",
+			"first done",
+		}, calls)
+	})
+}
+
+func TestClient_InsufficientPrivileges(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	storage := new(tinkofftest.SessionStorage)
+	require.NoError(t, storage.UpdateSession(context.Background(), server.Phone, server.NewSession()))
+	clock := newFakeClock(time.Now())
+	client := newTestClient(t, server, tinkoff.ClientParams{Clock: clock, SessionStorage: storage})
+	ctx := testContext(t, server)
+
+	// check mode requests are only sent with an existing session and never start an authorization
+	server.Script("/common/v1/ping", tinkoff.ResultCodeInsufficientPrivileges)
+	pingCtx, cancelPing := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Ping(pingCtx)
+	}()
+
+	clock.WaitTimer()
+	cancelPing()
+	<-done
+
+	assert.Equal(t, 1, server.Requests("/common/v1/ping"))
+	assert.Zero(t, server.Requests("/common/v1/session"))
+	info, err := client.SessionInfo(ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Active)
+
+	// force mode requests reauthorize and are retried with the new session
+	server.Script("/common/v1/accounts_light_ib", tinkoff.ResultCodeInsufficientPrivileges)
+	_, err = client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, server.Requests("/common/v1/session"))
+	assert.Equal(t, 2, server.Requests("/common/v1/accounts_light_ib"))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package tinkoff
+
+import "os"
+
+// Межпроцессная блокировка не поддерживается, доступ синхронизируется только внутри процесса.
+func lockFile(*os.File, bool) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
@@ -9,15 +9,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-type investError struct {
-	ErrorMessage string `json:"errorMessage"`
-	ErrorCode    string `json:"errorCode"`
-}
-
-func (e investError) Error() string {
-	return e.ErrorMessage + " (" + e.ErrorCode + ")"
-}
-
 type investExchange[R any] interface {
 	auth() bool
 	path() string
@@ -310,7 +301,7 @@ type InvestCandlesIn struct {
 	Ticker     string    `url:"ticker" validate:"required"`
 }
 
-func (InvestCandlesIn) auth() auth                { return force }
+func (InvestCandlesIn) auth() AuthMode            { return AuthForce }
 func (InvestCandlesIn) path() string              { return "/api/trading/symbols/candles" }
 func (InvestCandlesIn) out() (_ InvestCandlesOut) { return }
 func (InvestCandlesIn) exprc() string             { return "OK" }
@@ -0,0 +1,150 @@
+package tinkoff
+
+import (
+	"context"
+	"iter"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const operationsWindow = 30 * 24 * time.Hour
+
+// OperationsIter возвращает операции по счету account за период [from, to] в порядке возрастания времени операции.
+// Период разбивается на окна, каждое из которых запрашивается отдельным вызовом Operations.
+// Если to не задан, используется текущее время.
+func (c *Client) OperationsIter(ctx context.Context, account string, from, to time.Time) iter.Seq2[Operation, error] {
+	return func(yield func(Operation, error) bool) {
+		until := to
+		if until.IsZero() {
+			until = c.clock.Now()
+		}
+
+		// operations on window boundaries may be returned twice
+		var seen map[string]bool
+		for start := from; !start.After(until); {
+			if err := ctx.Err(); err != nil {
+				yield(Operation{}, err)
+				return
+			}
+
+			end := start.Add(operationsWindow)
+			if end.After(until) {
+				end = until
+			}
+
+			operations, err := c.Operations(ctx, &OperationsIn{
+				Account: account,
+				Start:   start,
+				End:     &end,
+			})
+
+			switch {
+			case errors.Is(err, ErrNoDataFound):
+				operations = nil
+			case err != nil:
+				yield(Operation{}, errors.Wrapf(err, "get operations from %s to %s", start, end))
+				return
+			}
+
+			sort.SliceStable(operations, func(i, j int) bool {
+				return operations[i].OperationTime.Time().Before(operations[j].OperationTime.Time())
+			})
+
+			next := make(map[string]bool, len(operations))
+			for _, operation := range operations {
+				if seen[operation.Id] {
+					continue
+				}
+
+				next[operation.Id] = true
+				if !yield(operation, nil) {
+					return
+				}
+			}
+
+			if !end.Before(until) {
+				return
+			}
+
+			seen = next
+			start = end
+		}
+	}
+}
+
+// InvestOperationsIter возвращает операции по брокерским счетам, последовательно запрашивая страницы
+// с помощью InvestOperations. Параметр Cursor в in задает начальную позицию и не изменяется.
+// Если in равен nil, запрашиваются все операции без ограничений.
+// Если types не пусто, возвращаются только операции с указанными типами.
+func (c *Client) InvestOperationsIter(ctx context.Context, in *InvestOperationsIn, types ...string) iter.Seq2[InvestOperation, error] {
+	return func(yield func(InvestOperation, error) bool) {
+		filter := make(map[string]bool, len(types))
+		for _, operationType := range types {
+			filter[operationType] = true
+		}
+
+		var page InvestOperationsIn
+		if in != nil {
+			page = *in
+		}
+
+		brokerAccountId := page.BrokerAccountId
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(InvestOperation{}, err)
+				return
+			}
+
+			out, err := c.InvestOperations(ctx, &page)
+			if err != nil {
+				yield(InvestOperation{}, errors.Wrapf(err, "get invest operations from cursor '%s'", page.Cursor))
+				return
+			}
+
+			for _, operation := range out.Items {
+				if brokerAccountId != "" && operation.BrokerAccountId != brokerAccountId {
+					continue
+				}
+
+				if len(filter) > 0 && !filter[operation.Type] {
+					continue
+				}
+
+				if !yield(operation, nil) {
+					return
+				}
+			}
+
+			if !out.HasNext {
+				return
+			}
+
+			if out.NextCursor == "" || out.NextCursor == page.Cursor {
+				yield(InvestOperation{}, errors.Errorf("invalid next cursor '%s'", out.NextCursor))
+				return
+			}
+
+			page.Cursor = out.NextCursor
+		}
+	}
+}
+
+// InvestOperationsAll возвращает все операции, полученные с помощью InvestOperationsIter.
+func (c *Client) InvestOperationsAll(ctx context.Context, in *InvestOperationsIn, types ...string) ([]InvestOperation, error) {
+	return collect(c.InvestOperationsIter(ctx, in, types...))
+}
+
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var values []T
+	for value, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
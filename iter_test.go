@@ -0,0 +1,87 @@
+package tinkoff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+func TestClient_OperationsIter(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	clock := newFakeClock(now)
+	from := now.Add(-90 * 24 * time.Hour)
+
+	server.AddOperations("1",
+		tinkoff.Operation{Id: "a", Account: "1", OperationTime: tinkoff.Milliseconds(from.Add(time.Hour))},
+		tinkoff.Operation{Id: "b", Account: "1", OperationTime: tinkoff.Milliseconds(now.Add(-45 * 24 * time.Hour))},
+		tinkoff.Operation{Id: "c", Account: "1", OperationTime: tinkoff.Milliseconds(now.Add(-time.Hour))})
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{Clock: clock})
+
+	// the sequence is reused after the clock moves, so the default end of the period must be evaluated on each run
+	operations := client.OperationsIter(ctx, "1", from, time.Time{})
+	ids := func() []string {
+		var ids []string
+		for operation, err := range operations {
+			require.NoError(t, err)
+			ids = append(ids, operation.Id)
+		}
+
+		return ids
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, ids())
+
+	clock.Advance(time.Hour)
+	server.AddOperations("1", tinkoff.Operation{Id: "d", Account: "1", OperationTime: tinkoff.Milliseconds(now.Add(time.Minute))})
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids())
+}
+
+func TestClient_InvestOperationsIter(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	now := time.Now().Truncate(time.Millisecond)
+	operation := func(id, account, operationType string, age time.Duration) tinkoff.InvestOperation {
+		return tinkoff.InvestOperation{
+			InternalId:      id,
+			BrokerAccountId: account,
+			Type:            operationType,
+			Date:            tinkoff.DateTimeMilliOffset(now.Add(-age)),
+		}
+	}
+
+	server.AddInvestOperations(
+		operation("a", "1", "Buy", time.Hour),
+		operation("b", "2", "Buy", 2*time.Hour),
+		operation("c", "1", "Sell", 3*time.Hour),
+		operation("d", "1", "Buy", 4*time.Hour),
+		operation("e", "2", "Sell", 5*time.Hour))
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{})
+
+	ids := func(in *tinkoff.InvestOperationsIn, types ...string) []string {
+		var ids []string
+		for operation, err := range client.InvestOperationsIter(ctx, in, types...) {
+			require.NoError(t, err)
+			ids = append(ids, operation.InternalId)
+		}
+
+		return ids
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids(nil))
+	assert.Equal(t, []string{"a", "c", "d"}, ids(&tinkoff.InvestOperationsIn{BrokerAccountId: "1", Limit: 2}))
+	assert.Equal(t, []string{"c", "e"}, ids(&tinkoff.InvestOperationsIn{Limit: 2}, "Sell"))
+	assert.Equal(t, []string{"a", "d"}, ids(&tinkoff.InvestOperationsIn{BrokerAccountId: "1", Limit: 1}, "Buy"))
+}
@@ -0,0 +1,148 @@
+package tinkoff
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	redactedLogKeys = map[string]bool{
+		"sessionid":  true,
+		"session_id": true,
+		"password":   true,
+	}
+
+	redactedLogPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(sessionid=)[^&\s"']+`),
+		regexp.MustCompile(`(?i)(password=)[^&\s"']+`),
+		regexp.MustCompile(`(psid=)[^;\s"']+`),
+	}
+
+	// номер телефона в формате +7XXXXXXXXXX или 7XXXXXXXXXX, не являющийся частью более длинного числа
+	phoneLogPattern = regexp.MustCompile(`(?:\+|\b)7\d{10}\b`)
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+// discardHandler отбрасывает все записи, не форматируя их.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// redactingHandler скрывает идентификаторы сессий, пароли и номера телефонов в записях журнала.
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, redactLogString(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactLogAttr(attr))
+		return true
+	})
+
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactLogAttr(attr)
+	}
+
+	return redactingHandler{Handler: h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redactLogAttr(attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	switch {
+	case redactedLogKeys[key]:
+		return slog.String(attr.Key, "REDACTED")
+	case key == "phone":
+		return slog.String(attr.Key, MaskPhone(attr.Value.String()))
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, attr := range group {
+			redacted[i] = redactLogAttr(attr)
+		}
+
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindString:
+		return slog.String(attr.Key, redactLogString(value.String()))
+	case slog.KindAny:
+		// values of arbitrary types are logged in their formatted form, so it is the one to redact
+		return slog.String(attr.Key, redactLogString(fmt.Sprint(value.Any())))
+	}
+
+	return attr
+}
+
+func redactLogString(str string) string {
+	for _, pattern := range redactedLogPatterns {
+		str = pattern.ReplaceAllString(str, "${1}REDACTED")
+	}
+
+	return phoneLogPattern.ReplaceAllStringFunc(str, MaskPhone)
+}
+
+// MaskPhone оставляет видимыми только последние 4 цифры номера телефона.
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// observeExchange записывает в журнал и метрики результат HTTP-запроса к API.
+func (c *Client) observeExchange(ctx context.Context, path string, start time.Time, status int, resultCode string, err error) {
+	duration := c.clock.Now().Sub(start)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("http.response.status_code", status),
+		attribute.String("tbank.result_code", resultCode),
+		attribute.Int("tbank.retry_count", getRetryAttempt(ctx)))
+
+	if c.metrics != nil {
+		c.metrics.ObserveRequest(path, resultCode, status, duration)
+	}
+
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("path", path),
+		slog.Duration("duration", duration),
+		slog.Int("status", status),
+		slog.String("result_code", resultCode),
+		slog.Int("attempt", getRetryAttempt(ctx)),
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	c.log.LogAttrs(ctx, level, "api exchange", attrs...)
+}
@@ -0,0 +1,52 @@
+package tinkoff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactLogString(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{in: "phone +79991234567 sent", out: "phone ********4567 sent"},
+		{in: "phone=79991234567", out: "phone=*******4567"},
+		{in: "operation 123456789012345", out: "operation 123456789012345"},
+		{in: "account 5123456789012", out: "account 5123456789012"},
+		{in: "url?sessionid=abc&password=secret", out: "url?sessionid=REDACTED&password=REDACTED"},
+		{in: "Cookie: psid=abc; other=1", out: "Cookie: psid=REDACTED; other=1"},
+	} {
+		assert.Equal(t, tc.out, redactLogString(tc.in), tc.in)
+	}
+}
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(redactingHandler{Handler: slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return attr
+		},
+	})})
+
+	log.With("sessionid", "abc").Info("login +79991234567",
+		slog.String("phone", "+79991234567"),
+		slog.Any("error", errors.New("get sessionid=abc")),
+		slog.Any("values", []string{"password=secret", "+79991234567"}),
+		slog.Group("request", slog.String("password", "secret")))
+
+	assert.Equal(t, `level=INFO msg="login ********4567" sessionid=REDACTED phone=********4567 `+
+		`error="get sessionid=REDACTED" values="[password=REDACTED ********4567]" request.password=REDACTED`+"\n", buf.String())
+}
+
+func TestDiscardLogger(t *testing.T) {
+	assert.False(t, discardLogger().Enabled(context.Background(), slog.LevelError))
+}
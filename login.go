@@ -0,0 +1,295 @@
+package tinkoff
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	loginPollInterval   = 100 * time.Millisecond
+	defaultLoginTimeout = time.Minute
+)
+
+// LoginAction – действие, выполняемое с элементом страницы входа.
+type LoginAction string
+
+const (
+	// LoginNone не выполняет действий: шаг только фиксирует появление элемента (например, признак успешного входа).
+	LoginNone LoginAction = "none"
+
+	// LoginEnterPhone вводит номер телефона.
+	LoginEnterPhone LoginAction = "phone"
+
+	// LoginEnterPassword вводит пароль, полученный от CredentialProvider.
+	LoginEnterPassword LoginAction = "password"
+
+	// LoginEnterCode вводит код подтверждения, полученный от Authorizer.
+	LoginEnterCode LoginAction = "code"
+
+	// LoginClick нажимает на элемент.
+	LoginClick LoginAction = "click"
+)
+
+// LoginStep описывает шаг авторизации на странице входа.
+// Шаг выполняется, как только на странице отображается элемент, соответствующий XPath, и выполняется не более одного раза.
+type LoginStep struct {
+	// Name используется в сообщениях об ошибках.
+	Name string
+
+	// XPath – селектор элемента.
+	XPath string
+
+	Action LoginAction
+
+	// Submit нажимает Enter после ввода текста.
+	Submit bool
+
+	// Terminal означает, что после выполнения шага авторизация завершена.
+	Terminal bool
+
+	// Optional означает, что шаг может не появиться на странице.
+	// Авторизация завершается ошибкой, если терминальный шаг выполнен раньше обязательного.
+	Optional bool
+
+	// Timeout – время ожидания элемента обязательного шага после выполнения предыдущего шага.
+	// Нулевое значение снимает ограничение.
+	Timeout time.Duration
+}
+
+// DefaultLoginSteps возвращает шаги авторизации для текущей версии страницы входа.
+func DefaultLoginSteps() []LoginStep {
+	return []LoginStep{
+		{
+			Name:    "phone",
+			XPath:   "//input[@automation-id='phone-input']",
+			Action:  LoginEnterPhone,
+			Submit:  true,
+			Timeout: defaultLoginTimeout,
+		},
+		{
+			Name:     "password",
+			XPath:    "//input[@automation-id='password-input']",
+			Action:   LoginEnterPassword,
+			Submit:   true,
+			Optional: true,
+		},
+		{
+			Name:     "otp",
+			XPath:    "//input[@automation-id='otp-input']",
+			Action:   LoginEnterCode,
+			Optional: true,
+		},
+		{
+			Name:     "cancel",
+			XPath:    "//button[@automation-id='cancel-button']",
+			Action:   LoginClick,
+			Optional: true,
+		},
+		{
+			Name:     "complete",
+			XPath:    "//a[@href='/new-product/']",
+			Action:   LoginNone,
+			Terminal: true,
+			Timeout:  defaultLoginTimeout,
+		},
+	}
+}
+
+// validateLoginSteps проверяет таблицу шагов до запуска браузера.
+// Пустое значение (nil) означает DefaultLoginSteps и считается корректным.
+func validateLoginSteps(steps []LoginStep) error {
+	if steps == nil {
+		return nil
+	}
+
+	terminal := false
+	for i, step := range steps {
+		if step.XPath == "" {
+			return errors.Errorf("login step %d ('%s'): xpath is required", i, step.Name)
+		}
+
+		switch step.Action {
+		case "", LoginNone, LoginClick, LoginEnterPhone, LoginEnterPassword, LoginEnterCode:
+		default:
+			return errors.Errorf("login step %d ('%s'): unknown action '%s'", i, step.Name, step.Action)
+		}
+
+		terminal = terminal || step.Terminal
+	}
+
+	if !terminal {
+		return errors.New("login steps must contain a terminal step")
+	}
+
+	return nil
+}
+
+// LoginTimeoutError возвращается, если элемент обязательного шага не появился на странице вовремя.
+type LoginTimeoutError struct {
+	// Step – шаг, элемент которого не появился.
+	Step string
+
+	// LastSeen – последний выполненный шаг (пустая строка, если ни один шаг не был выполнен).
+	LastSeen string
+
+	Timeout time.Duration
+
+	// Err – ошибка контекста, если ожидание было прервано.
+	Err error
+}
+
+func (e *LoginTimeoutError) Error() string {
+	lastSeen := e.LastSeen
+	if lastSeen == "" {
+		lastSeen = "none"
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("wait for login step '%s' (last seen step: %s): %s", e.Step, lastSeen, e.Err)
+	}
+
+	return fmt.Sprintf("login step '%s' did not appear within %s (last seen step: %s)", e.Step, e.Timeout, lastSeen)
+}
+
+func (e *LoginTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// browser – страница входа, открытая в браузере.
+type browser interface {
+	findElements(ctx context.Context, xpath string) ([]browserElement, error)
+}
+
+type browserElement interface {
+	isDisplayed(ctx context.Context) (bool, error)
+	sendKeys(ctx context.Context, text string, enter bool) error
+	click(ctx context.Context) error
+}
+
+// runLogin выполняет шаги авторизации до выполнения терминального шага.
+func runLogin(ctx context.Context, c *Client, authorizer Authorizer, b browser, steps []LoginStep) error {
+	if steps == nil {
+		steps = DefaultLoginSteps()
+	}
+
+	var (
+		done     = make([]bool, len(steps))
+		lastSeen string
+		progress = c.clock.Now()
+	)
+
+	for {
+		i, element, err := pollLogin(ctx, b, steps, done)
+		if err != nil {
+			return err
+		}
+
+		if element != nil {
+			step := steps[i]
+			if err := handleLoginStep(ctx, c, authorizer, element, step); err != nil {
+				return errors.Wrapf(err, "handle login step '%s'", step.Name)
+			}
+
+			done[i], lastSeen, progress = true, step.Name, c.clock.Now()
+			if step.Terminal {
+				for j, step := range steps {
+					if !done[j] && !step.Optional && !step.Terminal {
+						return errors.Errorf("login completed without required step '%s'", step.Name)
+					}
+				}
+
+				return nil
+			}
+
+			continue
+		}
+
+		next := nextLoginStep(steps, done)
+		if next >= 0 && steps[next].Timeout > 0 && c.clock.Now().Sub(progress) > steps[next].Timeout {
+			return &LoginTimeoutError{Step: steps[next].Name, LastSeen: lastSeen, Timeout: steps[next].Timeout}
+		}
+
+		if err := sleep(ctx, c.clock, loginPollInterval); err != nil {
+			step := "unknown"
+			if next >= 0 {
+				step = steps[next].Name
+			}
+
+			return &LoginTimeoutError{Step: step, LastSeen: lastSeen, Err: err}
+		}
+	}
+}
+
+// pollLogin возвращает невыполненный шаг, элемент которого отображается на странице, и сам элемент.
+// Если таких шагов нет, возвращается nil.
+func pollLogin(ctx context.Context, b browser, steps []LoginStep, done []bool) (int, browserElement, error) {
+	for i, step := range steps {
+		if done[i] {
+			continue
+		}
+
+		elements, err := b.findElements(ctx, step.XPath)
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "find xpath '%s'", step.XPath)
+		}
+
+		for _, element := range elements {
+			displayed, err := element.isDisplayed(ctx)
+			if err != nil {
+				return 0, nil, errors.Wrapf(err, "xpath '%s' is displayed", step.XPath)
+			}
+
+			if displayed {
+				return i, element, nil
+			}
+		}
+	}
+
+	return 0, nil, nil
+}
+
+// nextLoginStep возвращает индекс первого невыполненного обязательного шага или -1.
+func nextLoginStep(steps []LoginStep, done []bool) int {
+	for i, step := range steps {
+		if !done[i] && !step.Optional {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func handleLoginStep(ctx context.Context, c *Client, authorizer Authorizer, element browserElement, step LoginStep) error {
+	switch step.Action {
+	case LoginNone, "":
+		return nil
+	case LoginClick:
+		return element.click(ctx)
+	case LoginEnterPhone:
+		return element.sendKeys(ctx, c.phone, step.Submit)
+	case LoginEnterPassword:
+		password, err := c.password(ctx)
+		if err != nil {
+			return err
+		}
+
+		return element.sendKeys(ctx, password, step.Submit)
+	case LoginEnterCode:
+		code, err := authorizer.GetConfirmationCode(ctx, c.phone)
+		if err != nil {
+			return errors.Wrap(err, "get confirmation code")
+		}
+
+		if err := element.sendKeys(ctx, code, step.Submit); err != nil {
+			return err
+		}
+
+		c.observe(ctx, AuthCodeSubmitted, "", nil)
+		return nil
+	default:
+		return errors.Errorf("unknown login action '%s'", step.Action)
+	}
+}
@@ -0,0 +1,155 @@
+package tinkoff
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLoginElement struct {
+	displayed bool
+	keys      []string
+	clicks    int
+	onAction  func()
+}
+
+func (el *fakeLoginElement) isDisplayed(context.Context) (bool, error) {
+	return el.displayed, nil
+}
+
+func (el *fakeLoginElement) sendKeys(_ context.Context, text string, _ bool) error {
+	el.keys = append(el.keys, text)
+	if el.onAction != nil {
+		el.onAction()
+	}
+
+	return nil
+}
+
+func (el *fakeLoginElement) click(context.Context) error {
+	el.clicks++
+	if el.onAction != nil {
+		el.onAction()
+	}
+
+	return nil
+}
+
+// fakeLoginPage – страница входа, элементы которой задаются по XPath.
+type fakeLoginPage map[string]*fakeLoginElement
+
+func (p fakeLoginPage) findElements(_ context.Context, xpath string) ([]browserElement, error) {
+	if el, ok := p[xpath]; ok {
+		return []browserElement{el}, nil
+	}
+
+	return nil, nil
+}
+
+func newLoginTestClient(t *testing.T) *Client {
+	t.Helper()
+	client, err := NewClient(ClientParams{
+		Clock:          based.StandardClock,
+		SessionStorage: &FileSessionStorage{Path: filepath.Join(t.TempDir(), "sessions.json")},
+		Credential:     Credential{Phone: "+79999999999", Password: "password"},
+	})
+
+	require.NoError(t, err)
+	return client
+}
+
+var codeAuthorizer = AuthorizerFunc(func(context.Context, string) (string, error) { return "1234", nil })
+
+func TestRunLogin(t *testing.T) {
+	steps := []LoginStep{
+		{Name: "phone", XPath: "phone", Action: LoginEnterPhone, Submit: true, Timeout: 200 * time.Millisecond},
+		{Name: "password", XPath: "password", Action: LoginEnterPassword, Submit: true, Optional: true},
+		{Name: "otp", XPath: "otp", Action: LoginEnterCode, Timeout: 200 * time.Millisecond},
+		{Name: "complete", XPath: "complete", Action: LoginNone, Terminal: true, Timeout: 200 * time.Millisecond},
+	}
+
+	t.Run("optional step is skipped", func(t *testing.T) {
+		page := fakeLoginPage{
+			"phone":    {displayed: true},
+			"password": {},
+			"otp":      {},
+			"complete": {},
+		}
+
+		page["phone"].onAction = func() { page["phone"].displayed, page["otp"].displayed = false, true }
+		page["otp"].onAction = func() { page["otp"].displayed, page["complete"].displayed = false, true }
+
+		require.NoError(t, runLogin(context.Background(), newLoginTestClient(t), codeAuthorizer, page, steps))
+		assert.Equal(t, []string{"+79999999999"}, page["phone"].keys)
+		assert.Empty(t, page["password"].keys)
+		assert.Equal(t, []string{"1234"}, page["otp"].keys)
+	})
+
+	t.Run("required step does not appear", func(t *testing.T) {
+		page := fakeLoginPage{"phone": {displayed: true}}
+		err := runLogin(context.Background(), newLoginTestClient(t), codeAuthorizer, page, steps)
+
+		var timeoutErr *LoginTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		assert.Equal(t, "otp", timeoutErr.Step)
+		assert.Equal(t, "phone", timeoutErr.LastSeen)
+		assert.Equal(t, 200*time.Millisecond, timeoutErr.Timeout)
+		assert.NoError(t, timeoutErr.Err)
+		assert.EqualError(t, err, "login step 'otp' did not appear within 200ms (last seen step: phone)")
+	})
+
+	t.Run("context expires before any step", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := runLogin(ctx, newLoginTestClient(t), codeAuthorizer, fakeLoginPage{}, steps)
+
+		var timeoutErr *LoginTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		assert.Equal(t, "phone", timeoutErr.Step)
+		assert.Empty(t, timeoutErr.LastSeen)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("terminal step before required step", func(t *testing.T) {
+		page := fakeLoginPage{"phone": {displayed: true}, "complete": {displayed: true}}
+		err := runLogin(context.Background(), newLoginTestClient(t), codeAuthorizer, page, steps)
+		assert.EqualError(t, err, "login completed without required step 'otp'")
+	})
+}
+
+func TestValidateLoginSteps(t *testing.T) {
+	terminal := LoginStep{Name: "complete", XPath: "complete", Terminal: true}
+	for _, tc := range []struct {
+		name  string
+		steps []LoginStep
+		err   string
+	}{
+		{name: "default", steps: nil},
+		{name: "default steps", steps: DefaultLoginSteps()},
+		{name: "empty", steps: []LoginStep{}, err: "login steps must contain a terminal step"},
+		{name: "no terminal step", steps: []LoginStep{{Name: "phone", XPath: "phone", Action: LoginEnterPhone}}, err: "login steps must contain a terminal step"},
+		{name: "unknown action", steps: []LoginStep{{Name: "sms", XPath: "sms", Action: "sms"}, terminal}, err: "login step 0 ('sms'): unknown action 'sms'"},
+		{name: "missing xpath", steps: []LoginStep{terminal, {Name: "phone", Action: LoginEnterPhone}}, err: "login step 1 ('phone'): xpath is required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLoginSteps(tc.steps)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+
+	t.Run("browser is not started for invalid steps", func(t *testing.T) {
+		flow := &CDPAuthFlow{ExecPath: filepath.Join(t.TempDir(), "missing"), Steps: []LoginStep{}}
+		_, err := flow.authorize(context.Background(), newLoginTestClient(t), codeAuthorizer)
+		assert.EqualError(t, err, "login steps must contain a terminal step")
+	})
+}
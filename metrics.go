@@ -0,0 +1,34 @@
+package tinkoff
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsObserver получает сведения о выполнении запросов для сбора метрик.
+// Реализация для Prometheus находится в пакете github.com/jfk9w-go/tbank-api/metrics.
+// Методы вызываются синхронно и не должны блокироваться.
+type MetricsObserver interface {
+	// ObserveRequest вызывается после каждого HTTP-запроса к API.
+	// httpStatus равен 0, если ответ не был получен.
+	ObserveRequest(path, resultCode string, httpStatus int, duration time.Duration)
+
+	// ObserveRetry вызывается перед повтором запроса.
+	ObserveRetry(path string, attempt int, backoff time.Duration)
+
+	// ObserveRateLimitWait вызывается после ожидания ограничителя частоты запросов.
+	ObserveRateLimitWait(path string, wait time.Duration)
+}
+
+// waitRateLimit ожидает ограничитель частоты запросов для эндпоинта path.
+func (c *Client) waitRateLimit(ctx context.Context, path string) (context.Context, context.CancelFunc) {
+	start := c.clock.Now()
+	spanCtx, end := c.startChildSpan(ctx, "tbank.rate_limit.wait")
+	lockCtx, cancel := c.rateLimiter(path).Lock(spanCtx)
+	end(lockCtx.Err())
+	if c.metrics != nil {
+		c.metrics.ObserveRateLimitWait(path, c.clock.Now().Sub(start))
+	}
+
+	return detachSpan(lockCtx, ctx), cancel
+}
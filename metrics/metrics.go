@@ -0,0 +1,186 @@
+// Package metrics реализует сбор метрик клиента для Prometheus.
+//
+// Metrics реализует tinkoff.MetricsObserver и tinkoff.AuthObserver и передается в ClientParams (или PoolParams)
+// в поля Metrics и AuthObserver. Возраст сессий собирается для клиентов, переданных в Metrics.Track.
+// Метрики регистрируются в реестре Prometheus как единый коллектор:
+//
+//	registry.MustRegister(m)
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/prometheus/client_golang/prometheus"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+const defaultNamespace = "tbank"
+
+// SessionInfoProvider возвращает информацию о текущей сессии. Реализуется tinkoff.Client.
+type SessionInfoProvider interface {
+	SessionInfo(ctx context.Context) (*tinkoff.SessionInfo, error)
+}
+
+type Params struct {
+	Clock based.Clock `validate:"required"`
+
+	// Namespace – префикс имен метрик. По умолчанию "tbank".
+	Namespace string
+
+	// Buckets – границы интервалов гистограмм длительности в секундах. По умолчанию prometheus.DefBuckets.
+	Buckets []float64
+}
+
+// Metrics собирает метрики запросов, повторов, ожидания ограничителей частоты запросов и авторизаций.
+type Metrics struct {
+	clock         based.Clock
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	retries       *prometheus.CounterVec
+	backoff       *prometheus.HistogramVec
+	rateLimitWait *prometheus.HistogramVec
+	authEvents    *prometheus.CounterVec
+	sessionAge    *prometheus.Desc
+	sessionErrors prometheus.Counter
+	sessions      []SessionInfoProvider
+	mu            sync.RWMutex
+}
+
+func New(params Params) (*Metrics, error) {
+	if err := based.Validate(params); err != nil {
+		return nil, err
+	}
+
+	namespace := params.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	buckets := params.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+
+	return &Metrics{
+		clock: params.Clock,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of API requests by endpoint, result code and HTTP status.",
+		}, []string{"path", "result_code", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "API request duration by endpoint.",
+			Buckets:   buckets,
+		}, []string{"path"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Number of retried API requests by endpoint.",
+		}, []string{"path"}),
+		backoff: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "retry_backoff_seconds",
+			Help:      "Delay before retrying API request by endpoint.",
+			Buckets:   buckets,
+		}, []string{"path"}),
+		rateLimitWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_wait_seconds",
+			Help:      "Time spent waiting for rate limiters by endpoint.",
+			Buckets:   buckets,
+		}, []string{"path"}),
+		authEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_events_total",
+			Help:      "Number of authorization events by type and auth flow.",
+		}, []string{"event", "flow"}),
+		sessionAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "session_age_seconds"),
+			"Time since the current session was created.",
+			[]string{"session", "phone", "flow", "access_level"}, nil),
+		sessionErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "session_info_errors_total",
+			Help:      "Number of failed session info lookups during collection.",
+		}),
+	}, nil
+}
+
+// Track добавляет клиентов, для которых собирается возраст сессии.
+// Метка session содержит порядковый номер клиента среди добавленных, метка phone – маскированный номер телефона.
+func (m *Metrics) Track(sessions ...SessionInfoProvider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = append(m.sessions, sessions...)
+}
+
+func (m *Metrics) ObserveRequest(path, resultCode string, httpStatus int, duration time.Duration) {
+	m.requests.WithLabelValues(path, resultCode, strconv.Itoa(httpStatus)).Inc()
+	m.duration.WithLabelValues(path).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveRetry(path string, _ int, backoff time.Duration) {
+	m.retries.WithLabelValues(path).Inc()
+	m.backoff.WithLabelValues(path).Observe(backoff.Seconds())
+}
+
+func (m *Metrics) ObserveRateLimitWait(path string, wait time.Duration) {
+	m.rateLimitWait.WithLabelValues(path).Observe(wait.Seconds())
+}
+
+func (m *Metrics) OnAuthEvent(_ context.Context, event tinkoff.AuthEvent) {
+	m.authEvents.WithLabelValues(string(event.Type), event.Flow).Inc()
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.retries.Describe(ch)
+	m.backoff.Describe(ch)
+	m.rateLimitWait.Describe(ch)
+	m.authEvents.Describe(ch)
+	m.sessionErrors.Describe(ch)
+	ch <- m.sessionAge
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.collectSessions(ch)
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.retries.Collect(ch)
+	m.backoff.Collect(ch)
+	m.rateLimitWait.Collect(ch)
+	m.authEvents.Collect(ch)
+	m.sessionErrors.Collect(ch)
+}
+
+func (m *Metrics) collectSessions(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	sessions := m.sessions
+	m.mu.RUnlock()
+
+	now := m.clock.Now()
+	for i, provider := range sessions {
+		info, err := provider.SessionInfo(context.Background())
+		if err != nil {
+			m.sessionErrors.Inc()
+			continue
+		}
+
+		if !info.Active || info.CreatedAt.IsZero() {
+			continue
+		}
+
+		// masked phones may coincide, so the series is identified by the position in Track
+		ch <- prometheus.MustNewConstMetric(m.sessionAge, prometheus.GaugeValue,
+			now.Sub(info.CreatedAt).Seconds(),
+			strconv.Itoa(i), tinkoff.MaskPhone(info.Phone), info.AuthFlow, info.AccessLevel)
+	}
+}
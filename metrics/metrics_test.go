@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+type sessionInfoFunc func() *tinkoff.SessionInfo
+
+func (fn sessionInfoFunc) SessionInfo(context.Context) (*tinkoff.SessionInfo, error) {
+	return fn(), nil
+}
+
+func TestMetrics_SessionAge(t *testing.T) {
+	now := time.Now()
+	m, err := New(Params{Clock: based.ClockFunc(func() time.Time { return now })})
+	require.NoError(t, err)
+
+	session := func(phone string, createdAt time.Time) SessionInfoProvider {
+		return sessionInfoFunc(func() *tinkoff.SessionInfo {
+			return &tinkoff.SessionInfo{Phone: phone, Active: true, CreatedAt: createdAt, AuthFlow: "api"}
+		})
+	}
+
+	m.Track(
+		session("+79990001234", now.Add(-time.Minute)),
+		session("+79880001234", now.Add(-time.Hour)),
+		session("+79770001234", time.Time{}))
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(m))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	ages := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "tbank_session_age_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			assert.Equal(t, "********1234", labels["phone"])
+			ages[labels["session"]] = metric.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"0": 60, "1": 3600}, ages)
+}
@@ -0,0 +1,362 @@
+package tinkoff
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxDecimalScale = 18
+
+	// maxDecimalExponent ограничивает показатель степени в записи вида "1e-2".
+	// Большие значения не помещаются в Decimal, а их обработка заняла бы слишком много времени.
+	maxDecimalExponent = 40
+
+	// minorUnitsScale – количество знаков после запятой в копейках (центах).
+	minorUnitsScale = 2
+)
+
+var (
+	ErrDecimalOverflow   = errors.New("decimal overflow")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+	ErrInexactMinorUnits = errors.New("value is not representable in minor units")
+)
+
+// Decimal – точное десятичное число, хранящее значение unscaled * 10^-scale.
+// Дробная часть ограничена 18 знаками, а количество значащих цифр – диапазоном int64,
+// чего достаточно для денежных сумм и цен. Нулевое значение соответствует нулю.
+type Decimal struct {
+	unscaled int64
+	scale    uint8
+}
+
+// NewDecimal создает Decimal, равный unscaled * 10^-scale.
+func NewDecimal(unscaled int64, scale uint8) Decimal {
+	return Decimal{unscaled: unscaled, scale: scale}.normalize()
+}
+
+// ParseDecimal разбирает десятичную запись числа (например, "-123.45" или "1e-2") без потери точности.
+func ParseDecimal(str string) (Decimal, error) {
+	mantissa, exponent := str, 0
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		var err error
+		if exponent, err = strconv.Atoi(str[i+1:]); err != nil {
+			return Decimal{}, errors.Wrapf(err, "parse exponent in '%s'", str)
+		}
+
+		mantissa = str[:i]
+	}
+
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		scale = len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+
+	unscaled, err := strconv.ParseInt(mantissa, 10, 64)
+	if err != nil {
+		return Decimal{}, errors.Wrapf(err, "parse decimal '%s'", str)
+	}
+
+	if unscaled == 0 {
+		return Decimal{}, nil
+	}
+
+	if exponent > maxDecimalExponent || exponent < -maxDecimalExponent {
+		return Decimal{}, errors.Errorf("exponent in '%s' is out of range", str)
+	}
+
+	scale -= exponent
+	for ; scale < 0; scale++ {
+		if unscaled, err = mul(unscaled, 10); err != nil {
+			return Decimal{}, errors.Wrapf(err, "parse decimal '%s'", str)
+		}
+	}
+
+	// trailing zeros may be removed to fit the scale
+	for ; scale > maxDecimalScale && unscaled%10 == 0; scale-- {
+		unscaled /= 10
+	}
+
+	if scale > maxDecimalScale {
+		return Decimal{}, errors.Errorf("decimal '%s' has too many fractional digits", str)
+	}
+
+	return NewDecimal(unscaled, uint8(scale)), nil
+}
+
+// DecimalFromFloat преобразует float64 в Decimal по кратчайшей десятичной записи числа.
+// Для чисел, полученных из десятичной записи с не более чем 15 значащими цифрами, преобразование точное.
+func DecimalFromFloat(value float64) (Decimal, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Decimal{}, errors.Errorf("invalid decimal value %v", value)
+	}
+
+	return ParseDecimal(strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// normalize удаляет незначащие нули в дробной части.
+func (d Decimal) normalize() Decimal {
+	for d.scale > 0 && d.unscaled%10 == 0 {
+		d.unscaled /= 10
+		d.scale--
+	}
+
+	return d
+}
+
+func (d Decimal) rescale(scale uint8) (int64, error) {
+	value := d.unscaled
+	for s := d.scale; s < scale; s++ {
+		var err error
+		if value, err = mul(value, 10); err != nil {
+			return 0, err
+		}
+	}
+
+	return value, nil
+}
+
+func (d Decimal) align(other Decimal) (int64, int64, uint8, error) {
+	scale := max(d.scale, other.scale)
+	a, err := d.rescale(scale)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	b, err := other.rescale(scale)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return a, b, scale, nil
+}
+
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	a, b, scale, err := d.align(other)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	sum := a + b
+	if (sum > a) != (b > 0) {
+		return Decimal{}, ErrDecimalOverflow
+	}
+
+	return NewDecimal(sum, scale), nil
+}
+
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	return d.Add(other.Neg())
+}
+
+// Mul умножает число на целый множитель (например, цену на количество).
+func (d Decimal) Mul(factor int64) (Decimal, error) {
+	value, err := mul(d.unscaled, factor)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	return NewDecimal(value, d.scale), nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: -d.unscaled, scale: d.scale}
+}
+
+// Cmp возвращает -1, 0 или 1, если число меньше, равно или больше other.
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _, err := d.align(other)
+	if err != nil {
+		// overflow means that the value with the smaller scale has a greater magnitude
+		switch {
+		case d.Sign() != other.Sign():
+			return compareInts(d.Sign(), other.Sign())
+		case d.scale < other.scale:
+			return d.Sign()
+		default:
+			return -other.Sign()
+		}
+	}
+
+	return compareInts(a, b)
+}
+
+func compareInts[T int | int64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.unscaled < 0:
+		return -1
+	case d.unscaled > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.unscaled == 0
+}
+
+// MinorUnits возвращает значение в сотых долях (копейках, центах).
+// Если значение содержит более мелкие доли, возвращается ErrInexactMinorUnits.
+func (d Decimal) MinorUnits() (int64, error) {
+	if d.scale > minorUnitsScale {
+		return 0, ErrInexactMinorUnits
+	}
+
+	return d.rescale(minorUnitsScale)
+}
+
+func (d Decimal) Float64() float64 {
+	value, _ := strconv.ParseFloat(d.String(), 64)
+	return value
+}
+
+func (d Decimal) String() string {
+	str := strconv.FormatInt(d.unscaled, 10)
+	if d.scale == 0 {
+		return str
+	}
+
+	sign := ""
+	if d.unscaled < 0 {
+		sign, str = "-", str[1:]
+	}
+
+	if pad := int(d.scale) + 1 - len(str); pad > 0 {
+		str = strings.Repeat("0", pad) + str
+	}
+
+	point := len(str) - int(d.scale)
+	return sign + str[:point] + "." + str[point:]
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON принимает как числа, так и строки с десятичной записью числа.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(str, `"`) {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	}
+
+	value, err := ParseDecimal(str)
+	if err != nil {
+		return err
+	}
+
+	*d = value
+	return nil
+}
+
+func mul(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, ErrDecimalOverflow
+	}
+
+	return c, nil
+}
+
+// Money – точная денежная сумма в указанной валюте.
+type Money struct {
+	Value    Decimal `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency && !m.Value.IsZero() && !other.Value.IsZero() {
+		return Money{}, errors.Wrapf(ErrCurrencyMismatch, "%s and %s", m.Currency, other.Currency)
+	}
+
+	value, err := m.Value.Add(other.Value)
+	if err != nil {
+		return Money{}, err
+	}
+
+	currency := m.Currency
+	if currency == "" || m.Value.IsZero() {
+		currency = other.Currency
+	}
+
+	return Money{Value: value, Currency: currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+func (m Money) Neg() Money {
+	return Money{Value: m.Value.Neg(), Currency: m.Currency}
+}
+
+// MinorUnits возвращает сумму в сотых долях валюты (копейках, центах).
+func (m Money) MinorUnits() (int64, error) {
+	return m.Value.MinorUnits()
+}
+
+func (m Money) String() string {
+	return m.Value.String() + " " + m.Currency
+}
+
+// SumMoney складывает денежные суммы в одной валюте.
+func SumMoney(values ...Money) (Money, error) {
+	var sum Money
+	for _, value := range values {
+		var err error
+		if sum, err = sum.Add(value); err != nil {
+			return Money{}, err
+		}
+	}
+
+	return sum, nil
+}
+
+// Money возвращает сумму в виде Money.
+// Значение преобразуется из float64 (см. DecimalFromFloat); для точных значений используйте ExactMoneyAmount.
+func (a MoneyAmount) Money() (Money, error) {
+	value, err := DecimalFromFloat(a.Value)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Value: value, Currency: a.Currency.Name}, nil
+}
+
+// Money возвращает сумму в виде Money.
+// Значение преобразуется из float64 (см. DecimalFromFloat); для точных значений используйте ExactInvestAmount.
+func (a InvestAmount) Money() (Money, error) {
+	value, err := DecimalFromFloat(a.Value)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Value: value, Currency: strings.ToUpper(a.Currency)}, nil
+}
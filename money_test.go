@@ -0,0 +1,346 @@
+package tinkoff
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected Decimal
+		err      bool
+	}{
+		{input: "0", expected: Decimal{}},
+		{input: "-0.00", expected: Decimal{}},
+		{input: "123", expected: Decimal{unscaled: 123}},
+		{input: "-123.45", expected: Decimal{unscaled: -12345, scale: 2}},
+		{input: "+1.5", expected: Decimal{unscaled: 15, scale: 1}},
+		{input: ".5", expected: Decimal{unscaled: 5, scale: 1}},
+		{input: "1.50000", expected: Decimal{unscaled: 15, scale: 1}},
+		{input: "100.00", expected: Decimal{unscaled: 100}},
+		{input: "1e-2", expected: Decimal{unscaled: 1, scale: 2}},
+		{input: "1.5E3", expected: Decimal{unscaled: 1500}},
+		{input: "-2.5e-3", expected: Decimal{unscaled: -25, scale: 4}},
+		{input: "12e+2", expected: Decimal{unscaled: 1200}},
+		{input: "0.000000000000000001", expected: Decimal{unscaled: 1, scale: 18}},
+		{input: "0.0000000000000000010", expected: Decimal{unscaled: 1, scale: 18}},
+		{input: "9223372036854775807", expected: Decimal{unscaled: math.MaxInt64}},
+		{input: "0e999999999", expected: Decimal{}},
+		{input: "0e-999999999", expected: Decimal{}},
+		{input: "1e40", err: true},
+		{input: "1e41", err: true},
+		{input: "1e-41", err: true},
+		{input: "1e999999999", err: true},
+		{input: "0.0000000000000000001", err: true},
+		{input: "9223372036854775808", err: true},
+		{input: "922337203685477580.8e1", err: true},
+		{input: "", err: true},
+		{input: "abc", err: true},
+		{input: "1.2.3", err: true},
+		{input: "1e", err: true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			value, err := ParseDecimal(tc.input)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestParseDecimal_LargeExponentIsFast(t *testing.T) {
+	start := time.Now()
+	for _, input := range []string{"0e999999999", "1e999999999", "1e-999999999", "0.0e-2147483647"} {
+		_, _ = ParseDecimal(input)
+	}
+
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDecimalFromFloat(t *testing.T) {
+	for _, tc := range []struct {
+		input    float64
+		expected string
+		err      bool
+	}{
+		{input: 0, expected: "0"},
+		{input: 0.1, expected: "0.1"},
+		{input: 1.0 / 3, expected: "0.3333333333333333"},
+		{input: 1234.56, expected: "1234.56"},
+		{input: -99.99, expected: "-99.99"},
+		{input: 1e-5, expected: "0.00001"},
+		{input: 1e15, expected: "1000000000000000"},
+		{input: 1e20, err: true},
+		{input: math.NaN(), err: true},
+		{input: math.Inf(1), err: true},
+		{input: math.Inf(-1), err: true},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			value, err := DecimalFromFloat(tc.input)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value.String())
+		})
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	d := func(str string) Decimal {
+		value, err := ParseDecimal(str)
+		require.NoError(t, err)
+		return value
+	}
+
+	t.Run("add", func(t *testing.T) {
+		for _, tc := range []struct {
+			a, b, expected string
+		}{
+			{"0.1", "0.2", "0.3"},
+			{"1.05", "-1.05", "0"},
+			{"-1.5", "0.25", "-1.25"},
+			{"100", "0.01", "100.01"},
+			{"0.000000000000000001", "1", "1.000000000000000001"},
+		} {
+			sum, err := d(tc.a).Add(d(tc.b))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, sum.String(), "%s + %s", tc.a, tc.b)
+		}
+	})
+
+	t.Run("sub", func(t *testing.T) {
+		diff, err := d("10.00").Sub(d("0.01"))
+		require.NoError(t, err)
+		assert.Equal(t, "9.99", diff.String())
+	})
+
+	t.Run("mul", func(t *testing.T) {
+		for _, tc := range []struct {
+			a        string
+			factor   int64
+			expected string
+		}{
+			{"19.99", 3, "59.97"},
+			{"0.5", 2, "1"},
+			{"-0.01", 100, "-1"},
+			{"1.23", 0, "0"},
+		} {
+			product, err := d(tc.a).Mul(tc.factor)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, product.String(), "%s * %d", tc.a, tc.factor)
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := NewDecimal(math.MaxInt64, 0).Add(NewDecimal(1, 0))
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+
+		_, err = NewDecimal(math.MinInt64, 0).Add(NewDecimal(-1, 0))
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+
+		_, err = NewDecimal(math.MaxInt64, 0).Add(NewDecimal(1, 18))
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+
+		_, err = NewDecimal(math.MaxInt64/2+1, 0).Mul(2)
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+
+		_, err = NewDecimal(math.MinInt64, 0).Mul(-1)
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+	})
+
+	t.Run("cmp", func(t *testing.T) {
+		assert.Equal(t, 0, d("1.10").Cmp(d("1.1")))
+		assert.Equal(t, -1, d("-1").Cmp(d("0.5")))
+		assert.Equal(t, 1, d("0.000000000000000002").Cmp(d("0.000000000000000001")))
+		assert.Equal(t, 1, NewDecimal(math.MaxInt64, 0).Cmp(NewDecimal(1, 18)))
+		assert.Equal(t, -1, NewDecimal(-1, 18).Cmp(NewDecimal(math.MaxInt64, 0)))
+		assert.Equal(t, -1, NewDecimal(math.MinInt64, 0).Cmp(NewDecimal(-1, 18)))
+		assert.Equal(t, 1, NewDecimal(-1, 18).Cmp(NewDecimal(math.MinInt64, 0)))
+		assert.Equal(t, 1, NewDecimal(math.MaxInt64, 0).Cmp(NewDecimal(-1, 18)))
+	})
+
+	t.Run("minor units", func(t *testing.T) {
+		units, err := d("123.4").MinorUnits()
+		require.NoError(t, err)
+		assert.Equal(t, int64(12340), units)
+
+		units, err = d("-0.05").MinorUnits()
+		require.NoError(t, err)
+		assert.Equal(t, int64(-5), units)
+
+		_, err = d("0.001").MinorUnits()
+		assert.ErrorIs(t, err, ErrInexactMinorUnits)
+	})
+
+	t.Run("string", func(t *testing.T) {
+		assert.Equal(t, "0.05", NewDecimal(5, 2).String())
+		assert.Equal(t, "-0.05", NewDecimal(-5, 2).String())
+		assert.Equal(t, "12", NewDecimal(1200, 2).String())
+		assert.Equal(t, 12.34, NewDecimal(1234, 2).Float64())
+	})
+}
+
+func TestMoney(t *testing.T) {
+	rub := func(unscaled int64) Money {
+		return Money{Value: NewDecimal(unscaled, 2), Currency: "RUB"}
+	}
+
+	sum, err := SumMoney(rub(10), rub(20), rub(-5))
+	require.NoError(t, err)
+	assert.Equal(t, rub(25), sum)
+	assert.Equal(t, "0.25 RUB", sum.String())
+
+	// zero values take the currency of the other operand
+	sum, err = Money{}.Add(rub(1))
+	require.NoError(t, err)
+	assert.Equal(t, rub(1), sum)
+
+	_, err = rub(1).Add(Money{Value: NewDecimal(1, 0), Currency: "USD"})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	diff, err := rub(100).Sub(rub(1))
+	require.NoError(t, err)
+	units, err := diff.MinorUnits()
+	require.NoError(t, err)
+	assert.Equal(t, int64(99), units)
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	for _, tc := range []struct {
+		input, output string
+	}{
+		{`0.1`, `0.1`},
+		{`"0.1"`, `0.1`},
+		{`-12.50`, `-12.5`},
+		{`1e-2`, `0.01`},
+		{`100`, `100`},
+		{`123456789.123456789`, `123456789.123456789`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			var value Decimal
+			require.NoError(t, json.Unmarshal([]byte(tc.input), &value))
+
+			data, err := json.Marshal(value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.output, string(data))
+
+			var again Decimal
+			require.NoError(t, json.Unmarshal(data, &again))
+			assert.Equal(t, value, again)
+		})
+	}
+
+	var money struct {
+		Value *Decimal `json:"value"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"value":null}`), &money))
+	assert.Nil(t, money.Value)
+
+	var value Decimal
+	assert.Error(t, json.Unmarshal([]byte(`"abc"`), &value))
+	assert.Error(t, json.Unmarshal([]byte(`1e999`), &value))
+}
+
+func TestExactTypes_JSON(t *testing.T) {
+	t.Run("operation", func(t *testing.T) {
+		data := `{
+			"id": "1",
+			"account": "account",
+			"description": "coffee",
+			"amount": {"currency": {"name": "RUB"}, "value": 0.1},
+			"accountAmount": {"currency": {"name": "RUB"}, "value": 1234567.89},
+			"cashbackAmount": {"currency": {"name": "RUB"}, "value": 0}
+		}`
+
+		var operation ExactOperation
+		require.NoError(t, json.Unmarshal([]byte(data), &operation))
+
+		assert.Equal(t, "1", operation.Id)
+		assert.Equal(t, "coffee", operation.Description)
+		assert.Equal(t, Money{Value: NewDecimal(1, 1), Currency: "RUB"}, operation.Amount.Money())
+		assert.Equal(t, NewDecimal(123456789, 2), operation.AccountAmount.Value)
+		assert.True(t, operation.CashbackAmount.Value.IsZero())
+
+		// the embedded operation is decoded as usual
+		assert.Equal(t, 0.1, operation.Operation.Amount.Value)
+		assert.Equal(t, "RUB", operation.Operation.Amount.Currency.Name)
+		assert.Equal(t, 1234567.89, operation.Operation.AccountAmount.Value)
+
+		encoded, err := json.Marshal(operation)
+		require.NoError(t, err)
+
+		var again ExactOperation
+		require.NoError(t, json.Unmarshal(encoded, &again))
+		assert.Equal(t, operation.Amount, again.Amount)
+		assert.Equal(t, operation.AccountAmount, again.AccountAmount)
+		assert.Equal(t, operation.Operation.Amount, again.Operation.Amount)
+		assert.Equal(t, operation.Description, again.Description)
+	})
+
+	t.Run("receipt", func(t *testing.T) {
+		data := `{
+			"operationId": "1",
+			"receipt": {
+				"totalSum": 100.3,
+				"items": [
+					{"name": "a", "price": 33.43, "sum": 66.86, "quantity": 2},
+					{"name": "b", "price": 33.44, "sum": 33.44, "quantity": 1}
+				]
+			}
+		}`
+
+		var out ExactShoppingReceiptOut
+		require.NoError(t, json.Unmarshal([]byte(data), &out))
+
+		assert.Equal(t, "1", out.OperationId)
+		require.Len(t, out.Receipt.Items, 2)
+		assert.Equal(t, "a", out.Receipt.Items[0].Name)
+		assert.Equal(t, 2.0, out.Receipt.Items[0].Quantity)
+
+		var sum Decimal
+		for _, item := range out.Receipt.Items {
+			var err error
+			sum, err = sum.Add(item.Sum)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, 0, sum.Cmp(out.Receipt.TotalSum))
+		assert.Equal(t, 100.3, out.ShoppingReceiptOut.Receipt.TotalSum)
+		assert.Equal(t, 33.43, out.ShoppingReceiptOut.Receipt.Items[0].Price)
+	})
+
+	t.Run("invest operation", func(t *testing.T) {
+		data := `{
+			"internalId": "1",
+			"payment": {"currency": "rub", "value": -1000.01},
+			"price": {"currency": "rub", "value": 100.001},
+			"commission": {"currency": "rub", "value": 0.3}
+		}`
+
+		var operation ExactInvestOperation
+		require.NoError(t, json.Unmarshal([]byte(data), &operation))
+
+		assert.Equal(t, "1", operation.InternalId)
+		assert.Equal(t, Money{Value: NewDecimal(-100001, 2), Currency: "RUB"}, operation.Payment.Money())
+		require.NotNil(t, operation.Price)
+		assert.Equal(t, NewDecimal(100001, 3), operation.Price.Value)
+		require.NotNil(t, operation.Commission)
+		assert.Equal(t, NewDecimal(3, 1), operation.Commission.Value)
+		assert.Nil(t, operation.Yield)
+		assert.Equal(t, -1000.01, operation.InvestOperation.Payment.Value)
+	})
+}
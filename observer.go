@@ -0,0 +1,113 @@
+package tinkoff
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AuthEventType – тип события авторизации.
+type AuthEventType string
+
+const (
+	// AuthSessionInvalidated – сервер отклонил сессию, либо она потеряла уровень доступа CLIENT.
+	AuthSessionInvalidated AuthEventType = "session_invalidated"
+
+	// AuthStarted – начата авторизация.
+	AuthStarted AuthEventType = "started"
+
+	// AuthCodeRequested – запрошен код подтверждения у Authorizer.
+	AuthCodeRequested AuthEventType = "code_requested"
+
+	// AuthCodeSubmitted – код подтверждения отправлен в банк.
+	AuthCodeSubmitted AuthEventType = "code_submitted"
+
+	// AuthSucceeded – авторизация завершена успешно.
+	AuthSucceeded AuthEventType = "succeeded"
+
+	// AuthFailed – авторизация завершилась ошибкой.
+	AuthFailed AuthEventType = "failed"
+)
+
+// AuthEvent описывает событие авторизации.
+type AuthEvent struct {
+	Type  AuthEventType
+	Phone string
+
+	// Flow – способ аутентификации (см. Session.AuthFlow).
+	Flow string
+
+	Time time.Time
+
+	// Reason – причина инвалидации сессии для AuthSessionInvalidated.
+	Reason string
+
+	// Err – ошибка для AuthFailed.
+	Err error
+}
+
+// AuthObserver получает события авторизации.
+// Методы вызываются синхронно в процессе выполнения запроса, поэтому не должны блокироваться надолго.
+type AuthObserver interface {
+	OnAuthEvent(ctx context.Context, event AuthEvent)
+}
+
+// AuthObserverFunc – функциональный адаптер для AuthObserver.
+type AuthObserverFunc func(ctx context.Context, event AuthEvent)
+
+func (fn AuthObserverFunc) OnAuthEvent(ctx context.Context, event AuthEvent) {
+	fn(ctx, event)
+}
+
+// AuthObservers рассылает события всем наблюдателям.
+type AuthObservers []AuthObserver
+
+func (observers AuthObservers) OnAuthEvent(ctx context.Context, event AuthEvent) {
+	for _, observer := range observers {
+		observer.OnAuthEvent(ctx, event)
+	}
+}
+
+func (c *Client) observe(ctx context.Context, eventType AuthEventType, reason string, err error) {
+	flow := authFlowName(c.authFlow)
+	attrs := []slog.Attr{slog.String("event", string(eventType)), slog.String("flow", flow)}
+	level := slog.LevelInfo
+	if reason != "" {
+		attrs = append(attrs, slog.String("reason", reason))
+	}
+
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	c.log.LogAttrs(ctx, level, "authorization", attrs...)
+	trace.SpanFromContext(ctx).AddEvent("tbank.auth."+string(eventType), trace.WithAttributes(attribute.String("tbank.auth_flow", flow)))
+
+	if c.authObserver == nil {
+		return
+	}
+
+	c.authObserver.OnAuthEvent(ctx, AuthEvent{
+		Type:   eventType,
+		Phone:  c.phone,
+		Flow:   flow,
+		Time:   c.clock.Now(),
+		Reason: reason,
+		Err:    err,
+	})
+}
+
+// observedAuthorizer сообщает о запросе кода подтверждения.
+type observedAuthorizer struct {
+	Authorizer
+	client *Client
+}
+
+func (a observedAuthorizer) GetConfirmationCode(ctx context.Context, phone string) (string, error) {
+	a.client.observe(ctx, AuthCodeRequested, "", nil)
+	return a.Authorizer.GetConfirmationCode(ctx, phone)
+}
@@ -0,0 +1,100 @@
+package tinkoff_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+type authRecorder struct {
+	events []tinkoff.AuthEvent
+	mu     sync.Mutex
+}
+
+func (r *authRecorder) OnAuthEvent(_ context.Context, event tinkoff.AuthEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *authRecorder) types() []tinkoff.AuthEventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]tinkoff.AuthEventType, len(r.events))
+	for i, event := range r.events {
+		types[i] = event.Type
+	}
+
+	r.events = nil
+	return types
+}
+
+func TestClient_AuthObserver(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		recorder, other := new(authRecorder), new(authRecorder)
+		client := newTestClient(t, server, tinkoff.ClientParams{
+			Clock:        newFakeClock(now),
+			AuthObserver: tinkoff.AuthObservers{recorder, other},
+		})
+
+		_, err := client.AccountsLightIb(testContext(t, server))
+		require.NoError(t, err)
+
+		require.Len(t, recorder.events, 4)
+		for _, event := range recorder.events {
+			assert.Equal(t, server.Phone, event.Phone)
+			assert.Equal(t, "api", event.Flow)
+			assert.Equal(t, now, event.Time)
+			assert.NoError(t, event.Err)
+		}
+
+		expected := []tinkoff.AuthEventType{tinkoff.AuthStarted, tinkoff.AuthCodeRequested, tinkoff.AuthCodeSubmitted, tinkoff.AuthSucceeded}
+		assert.Equal(t, expected, recorder.types())
+		assert.Equal(t, expected, other.types())
+
+		t.Run("session rejected", func(t *testing.T) {
+			server.Script("/common/v1/accounts_light_ib", tinkoff.ResultCodeInsufficientPrivileges)
+			_, err := client.AccountsLightIb(testContext(t, server))
+			require.NoError(t, err)
+
+			require.NotEmpty(t, recorder.events)
+			assert.NotEmpty(t, recorder.events[0].Reason)
+			assert.Equal(t, []tinkoff.AuthEventType{
+				tinkoff.AuthSessionInvalidated,
+				tinkoff.AuthStarted, tinkoff.AuthCodeRequested, tinkoff.AuthCodeSubmitted, tinkoff.AuthSucceeded,
+			}, recorder.types())
+		})
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		server := tinkofftest.NewServer()
+		defer server.Close()
+
+		recorder := new(authRecorder)
+		client := newTestClient(t, server, tinkoff.ClientParams{AuthObserver: recorder})
+
+		authErr := errors.New("no code")
+		ctx := tinkoff.WithAuthorizer(testContext(t, server), tinkoff.AuthorizerFunc(func(context.Context, string) (string, error) {
+			return "", authErr
+		}))
+
+		_, err := client.AccountsLightIb(ctx)
+		require.ErrorIs(t, err, authErr)
+
+		require.Len(t, recorder.events, 3)
+		assert.ErrorIs(t, recorder.events[2].Err, authErr)
+		assert.Equal(t, []tinkoff.AuthEventType{tinkoff.AuthStarted, tinkoff.AuthCodeRequested, tinkoff.AuthFailed}, recorder.types())
+	})
+}
@@ -0,0 +1,240 @@
+package tinkoff
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultPoolConcurrency = 4
+
+type PoolParams struct {
+	Clock          based.Clock        `validate:"required"`
+	Credentials    CredentialProvider `validate:"required"`
+	SessionStorage SessionStorage     `validate:"required"`
+
+	// Phones – номера телефонов, для которых выполняются ForEach и Ping.
+	// Клиенты для других номеров создаются при вызове Get и также добавляются в пул.
+	Phones []string
+
+	AuthFlow    AuthFlow
+	Transport   http.RoundTripper
+	BaseURL     string `validate:"omitempty,url"`
+	RetryPolicy RetryPolicy
+
+	// AuthObserver получает события авторизации всех клиентов пула.
+	AuthObserver AuthObserver
+
+	// Metrics получает сведения о запросах всех клиентов пула.
+	Metrics MetricsObserver
+
+	// TracerProvider используется клиентами пула для трассировки запросов.
+	TracerProvider trace.TracerProvider
+
+	// Middleware оборачивают запросы всех клиентов пула.
+	Middleware []Middleware
+
+	// Logger – журнал клиентов пула.
+	Logger *slog.Logger
+
+	// RateLimits хранит ограничители частоты запросов для каждого номера телефона.
+	// По умолчанию для каждого номера используется DefaultRateLimits.
+	RateLimits *RateLimitRegistry
+
+	// SessionIdleTimeout используется клиентами пула для оценки времени истечения сессии (см. ClientParams).
+	SessionIdleTimeout time.Duration `validate:"gte=0"`
+
+	// ClientConcurrency – максимальное количество одновременно выполняемых HTTP-запросов каждого клиента пула
+	// (см. ClientParams.Concurrency).
+	ClientConcurrency int `validate:"gte=0"`
+
+	// Concurrency – максимальное количество одновременно выполняемых вызовов в ForEach и Ping. По умолчанию 4.
+	Concurrency int `validate:"gte=0"`
+}
+
+// Pool лениво создает клиентов для нескольких номеров телефонов.
+// Клиенты разделяют общие SessionStorage и транспорт.
+type Pool struct {
+	params  PoolParams
+	phones  []string
+	clients map[string]*Client
+	mu      sync.Mutex
+}
+
+func NewPool(params PoolParams) (*Pool, error) {
+	if err := based.Validate(params); err != nil {
+		return nil, err
+	}
+
+	if params.RateLimits == nil {
+		clock := params.Clock
+		params.RateLimits = NewRateLimitRegistry(func() RateLimits { return DefaultRateLimits(clock) })
+	}
+
+	if params.Concurrency == 0 {
+		params.Concurrency = defaultPoolConcurrency
+	}
+
+	var phones []string
+	for _, phone := range params.Phones {
+		if !slices.Contains(phones, phone) {
+			phones = append(phones, phone)
+		}
+	}
+
+	return &Pool{
+		params:  params,
+		phones:  phones,
+		clients: make(map[string]*Client),
+	}, nil
+}
+
+// Phones возвращает номера телефонов пула.
+func (p *Pool) Phones() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.phones)
+}
+
+// Get возвращает клиента для номера телефона phone, создавая его при необходимости.
+func (p *Pool) Get(ctx context.Context, phone string) (*Client, error) {
+	p.mu.Lock()
+	client, ok := p.clients[phone]
+	p.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	client, err := NewClient(ClientParams{
+		Clock:              p.params.Clock,
+		Phone:              phone,
+		Credentials:        p.params.Credentials,
+		SessionStorage:     p.params.SessionStorage,
+		AuthFlow:           p.params.AuthFlow,
+		Transport:          p.params.Transport,
+		BaseURL:            p.params.BaseURL,
+		RetryPolicy:        p.params.RetryPolicy,
+		RateLimits:         p.params.RateLimits.Get(phone),
+		AuthObserver:       p.params.AuthObserver,
+		Logger:             p.params.Logger,
+		Metrics:            p.params.Metrics,
+		TracerProvider:     p.params.TracerProvider,
+		Middleware:         p.params.Middleware,
+		SessionIdleTimeout: p.params.SessionIdleTimeout,
+		Concurrency:        p.params.ClientConcurrency,
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "create client for %s", phone)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.clients[phone]; ok {
+		// another call created the client while this one was building its own
+		return existing, nil
+	}
+
+	p.clients[phone] = client
+	if !slices.Contains(p.phones, phone) {
+		p.phones = append(p.phones, phone)
+	}
+
+	return client, nil
+}
+
+// Ping поддерживает активными сессии для всех номеров телефонов пула (см. Pool.Phones),
+// при необходимости создавая клиентов. В отличие от Client.Ping, для всех клиентов используется один планировщик.
+func (p *Pool) Ping(ctx context.Context) {
+	for {
+		phones := p.Phones()
+		runBounded(ctx, len(phones), p.params.Concurrency, func(ctx context.Context, i int) {
+			client, err := p.Get(ctx, phones[i])
+			if err != nil {
+				return
+			}
+
+			_ = client.ping(ctx)
+		})
+
+		if err := sleep(ctx, p.params.Clock, pingInterval); err != nil {
+			return
+		}
+	}
+}
+
+// PoolResult – результат вызова для одного номера телефона.
+type PoolResult[R any] struct {
+	Phone string
+	Value R
+	Err   error
+}
+
+// ForEach выполняет fn для каждого номера телефона пула, ограничивая количество одновременных вызовов.
+// Результаты возвращаются в порядке номеров телефонов в Pool.Phones.
+// После отмены ctx новые вызовы не начинаются, а результаты для оставшихся номеров содержат ошибку ctx.Err().
+func ForEach[R any](ctx context.Context, pool *Pool, fn func(ctx context.Context, client *Client) (R, error)) []PoolResult[R] {
+	phones := pool.Phones()
+	results := make([]PoolResult[R], len(phones))
+	for i, phone := range phones {
+		results[i].Phone = phone
+	}
+
+	started := runBounded(ctx, len(phones), pool.params.Concurrency, func(ctx context.Context, i int) {
+		result := &results[i]
+		client, err := pool.Get(ctx, result.Phone)
+		if err != nil {
+			result.Err = err
+			return
+		}
+
+		result.Value, result.Err = fn(ctx, client)
+	})
+
+	for i := started; i < len(results); i++ {
+		results[i].Err = ctx.Err()
+	}
+
+	return results
+}
+
+// runBounded выполняет fn для i от 0 до n-1, не более concurrency вызовов одновременно.
+// После отмены ctx новые вызовы не начинаются. Возвращает количество начатых вызовов.
+func runBounded(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int)) int {
+	var (
+		slots = make(chan struct{}, concurrency)
+		wg    sync.WaitGroup
+	)
+
+	defer wg.Wait()
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			return i
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return i
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			fn(ctx, i)
+		}()
+	}
+
+	return n
+}
@@ -0,0 +1,191 @@
+package tinkoff_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+func newTestPool(t *testing.T, server *tinkofftest.Server, clock *fakeClock, storage tinkoff.SessionStorage, phones ...string) *tinkoff.Pool {
+	t.Helper()
+	pool, err := tinkoff.NewPool(tinkoff.PoolParams{
+		Clock:          clock,
+		Credentials:    tinkoff.StaticCredentials(tinkoff.Credential{Phone: server.Phone, Password: server.Password}),
+		SessionStorage: storage,
+		Phones:         phones,
+		BaseURL:        server.URL,
+		RetryPolicy:    tinkoff.NoRetryPolicy,
+		Concurrency:    1,
+	})
+
+	require.NoError(t, err)
+	return pool
+}
+
+func TestPool_PingCreatesClients(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := new(tinkofftest.SessionStorage)
+	require.NoError(t, storage.UpdateSession(ctx, server.Phone, server.NewSession()))
+
+	clock := newFakeClock(time.Now())
+	pool := newTestPool(t, server, clock, storage, server.Phone)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.Ping(ctx)
+	}()
+
+	// the first round is complete once Ping waits for the next one
+	clock.WaitTimer()
+	assert.Equal(t, 1, server.Requests("/common/v1/ping"))
+
+	clock.Advance(time.Hour)
+	clock.WaitTimer()
+	assert.Equal(t, 2, server.Requests("/common/v1/ping"))
+
+	cancel()
+	<-done
+}
+
+func TestForEach_StopsOnCancel(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	phones := []string{"+79990000001", "+79990000002", "+79990000003"}
+	pool := newTestPool(t, server, newFakeClock(time.Now()), new(tinkofftest.SessionStorage), phones...)
+
+	t.Run("cancelled before start", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var calls atomic.Int32
+		results := tinkoff.ForEach(ctx, pool, func(context.Context, *tinkoff.Client) (struct{}, error) {
+			calls.Add(1)
+			return struct{}{}, nil
+		})
+
+		assert.Zero(t, calls.Load())
+		require.Len(t, results, len(phones))
+		for i, result := range results {
+			assert.Equal(t, phones[i], result.Phone)
+			assert.ErrorIs(t, result.Err, context.Canceled)
+		}
+	})
+
+	t.Run("cancelled while running", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var calls atomic.Int32
+		results := tinkoff.ForEach(ctx, pool, func(context.Context, *tinkoff.Client) (int, error) {
+			calls.Add(1)
+			cancel()
+			return 1, nil
+		})
+
+		assert.Equal(t, int32(1), calls.Load())
+		require.Len(t, results, len(phones))
+		assert.Equal(t, 1, results[0].Value)
+		assert.NoError(t, results[0].Err)
+		for _, result := range results[1:] {
+			assert.ErrorIs(t, result.Err, context.Canceled)
+		}
+	})
+}
+
+func TestPool_ClientParams(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(tinkoff.WithAuthorizer(context.Background(), server.Authorizer()))
+	defer cancel()
+
+	storage := new(tinkofftest.SessionStorage)
+	require.NoError(t, storage.UpdateSession(ctx, server.Phone, server.NewSession()))
+
+	clock := newFakeClock(time.Now())
+	pool, err := tinkoff.NewPool(tinkoff.PoolParams{
+		Clock:              clock,
+		Credentials:        tinkoff.StaticCredentials(tinkoff.Credential{Phone: server.Phone, Password: server.Password}),
+		SessionStorage:     storage,
+		Phones:             []string{server.Phone},
+		BaseURL:            server.URL,
+		RetryPolicy:        tinkoff.NoRetryPolicy,
+		SessionIdleTimeout: 5 * time.Minute,
+		ClientConcurrency:  1,
+	})
+
+	require.NoError(t, err)
+
+	t.Run("concurrent get returns one client", func(t *testing.T) {
+		clients := make(chan *tinkoff.Client, 8)
+		for range cap(clients) {
+			go func() {
+				client, err := pool.Get(ctx, server.Phone)
+				assert.NoError(t, err)
+				clients <- client
+			}()
+		}
+
+		first := <-clients
+		for range cap(clients) - 1 {
+			assert.Same(t, first, <-clients)
+		}
+	})
+
+	client, err := pool.Get(ctx, server.Phone)
+	require.NoError(t, err)
+
+	t.Run("session idle timeout", func(t *testing.T) {
+		pingCtx, cancelPing := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pool.Ping(pingCtx)
+		}()
+
+		clock.WaitTimer()
+		cancelPing()
+		<-done
+
+		info, err := client.SessionInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, clock.Now().Add(5*time.Minute), info.ExpiresAt)
+	})
+
+	t.Run("client concurrency", func(t *testing.T) {
+		held, release := server.Hold("/common/v1/accounts_light_ib")
+		errs := make(chan error, 2)
+		for range cap(errs) {
+			go func() {
+				_, err := client.AccountsLightIb(ctx)
+				errs <- err
+			}()
+		}
+
+		<-held
+		select {
+		case <-held:
+			t.Error("second request must wait for a free slot")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		release()
+		for range cap(errs) {
+			assert.NoError(t, <-errs)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package tinkoff
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfk9w-go/based"
+)
+
+// RateLimits задает ограничители частоты запросов.
+// Ключ – путь эндпоинта ("/common/v1/operations") или группа эндпоинтов, заданная префиксом с завершающим "/"
+// ("/invest-gw/"). К запросу применяются все подходящие ограничители, от более общих к более частным.
+// Один и тот же based.Locker можно использовать в нескольких клиентах, чтобы они разделяли общий лимит.
+type RateLimits map[string]based.Locker
+
+// DefaultRateLimits возвращает ограничители, которые используются клиентом по умолчанию.
+func DefaultRateLimits(clock based.Clock) RateLimits {
+	return RateLimits{
+		shoppingReceiptPath: based.Lockers{
+			SlidingWindow(clock, 25, 75*time.Second),
+			SlidingWindow(clock, 75, 11*time.Minute),
+		},
+	}
+}
+
+func (l RateLimits) get(path string) based.Locker {
+	var keys []string
+	for key := range l {
+		if key == path || strings.HasSuffix(key, "/") && strings.HasPrefix(path, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	switch len(keys) {
+	case 0:
+		return based.Unlocker
+	case 1:
+		return l[keys[0]]
+	}
+
+	// consistent order prevents deadlocks between concurrent requests
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) < len(keys[j]) })
+	lockers := make(based.Lockers, len(keys))
+	for i, key := range keys {
+		lockers[i] = l[key]
+	}
+
+	return lockers
+}
+
+// SlidingWindow допускает не более size запросов за любой интервал длительностью interval.
+// Ожидание выполняется по часам clock (см. TimerClock) и прерывается при отмене контекста.
+func SlidingWindow(clock based.Clock, size int, interval time.Duration) based.Locker {
+	if size <= 0 {
+		return based.Unlocker
+	}
+
+	slots := make(chan time.Time, size)
+	for i := 0; i < size; i++ {
+		slots <- clock.Now().Add(-interval)
+	}
+
+	return &slidingWindow{
+		clock:    clock,
+		interval: interval,
+		slots:    slots,
+	}
+}
+
+type slidingWindow struct {
+	clock    based.Clock
+	interval time.Duration
+	slots    chan time.Time
+}
+
+func (w *slidingWindow) Lock(ctx context.Context) (context.Context, context.CancelFunc) {
+	return based.ReentrantLock(ctx, w, 1, w.doLock)
+}
+
+func (w *slidingWindow) doLock(ctx context.Context) (context.Context, context.CancelFunc) {
+	select {
+	case last := <-w.slots:
+		if err := sleep(ctx, w.clock, last.Add(w.interval).Sub(w.clock.Now())); err != nil {
+			// slot was not used, so it keeps its previous time
+			w.slots <- last
+			return ctx, based.Nop
+		}
+
+		return ctx, func() { w.slots <- w.clock.Now() }
+	case <-ctx.Done():
+		return ctx, based.Nop
+	}
+}
+
+type tokenBucket struct {
+	clock    based.Clock
+	capacity float64
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+	mu       sync.Mutex
+}
+
+// TokenBucket допускает всплески до capacity запросов, пополняя запас на один запрос каждые interval.
+func TokenBucket(clock based.Clock, capacity int, interval time.Duration) based.Locker {
+	if capacity <= 0 || interval <= 0 {
+		return based.Unlocker
+	}
+
+	return &tokenBucket{
+		clock:    clock,
+		capacity: float64(capacity),
+		interval: interval,
+		tokens:   float64(capacity),
+		last:     clock.Now(),
+	}
+}
+
+func (b *tokenBucket) Lock(ctx context.Context) (context.Context, context.CancelFunc) {
+	return based.ReentrantLock(ctx, b, 1, b.doLock)
+}
+
+func (b *tokenBucket) doLock(ctx context.Context) (context.Context, context.CancelFunc) {
+	for {
+		wait := b.take()
+		if wait <= 0 {
+			return ctx, based.Nop
+		}
+
+		if err := sleep(ctx, b.clock, wait); err != nil {
+			return ctx, based.Nop
+		}
+	}
+}
+
+// take забирает токен и возвращает 0, либо возвращает время ожидания следующего токена.
+func (b *tokenBucket) take() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+float64(elapsed)/float64(b.interval))
+	}
+
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) * float64(b.interval))
+}
+
+// RateLimitRegistry хранит ограничители для каждого номера телефона,
+// чтобы несколько клиентов с одним номером разделяли общий лимит запросов.
+type RateLimitRegistry struct {
+	newRateLimits func() RateLimits
+	rateLimits    map[string]RateLimits
+	mu            sync.Mutex
+}
+
+// NewRateLimitRegistry создает RateLimitRegistry.
+// newRateLimits вызывается один раз для каждого номера телефона.
+func NewRateLimitRegistry(newRateLimits func() RateLimits) *RateLimitRegistry {
+	return &RateLimitRegistry{
+		newRateLimits: newRateLimits,
+		rateLimits:    make(map[string]RateLimits),
+	}
+}
+
+// Get возвращает ограничители для номера телефона phone.
+func (r *RateLimitRegistry) Get(phone string) RateLimits {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rateLimits, ok := r.rateLimits[phone]
+	if !ok {
+		rateLimits = r.newRateLimits()
+		r.rateLimits[phone] = rateLimits
+	}
+
+	return rateLimits
+}
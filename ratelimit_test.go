@@ -0,0 +1,172 @@
+package tinkoff_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+// lockAsync захватывает locker в отдельной горутине. Канал закрывается после завершения захвата.
+func lockAsync(ctx context.Context, locker based.Locker) (<-chan struct{}, *context.CancelFunc) {
+	done := make(chan struct{})
+	release := new(context.CancelFunc)
+	go func() {
+		defer close(done)
+		_, *release = locker.Lock(ctx)
+	}()
+
+	return done, release
+}
+
+func lock(t *testing.T, locker based.Locker) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := locker.Lock(context.Background())
+	require.NoError(t, ctx.Err())
+	return cancel
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := newFakeClock(time.Now())
+	bucket := tinkoff.TokenBucket(clock, 2, time.Minute)
+
+	// burst up to capacity does not wait
+	lock(t, bucket)()
+	lock(t, bucket)()
+
+	done, _ := lockAsync(context.Background(), bucket)
+	clock.WaitTimer()
+	clock.Advance(30 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("token must not be available before refill")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(30 * time.Second)
+	<-done
+
+	// tokens do not accumulate beyond capacity
+	clock.Advance(time.Hour)
+	lock(t, bucket)()
+	lock(t, bucket)()
+	done, _ = lockAsync(context.Background(), bucket)
+	clock.WaitTimer()
+	clock.Advance(time.Minute)
+	<-done
+}
+
+func TestSlidingWindow(t *testing.T) {
+	t.Run("window expiry", func(t *testing.T) {
+		clock := newFakeClock(time.Now())
+		window := tinkoff.SlidingWindow(clock, 2, time.Minute)
+
+		lock(t, window)()
+		clock.Advance(30 * time.Second)
+		lock(t, window)()
+
+		done, _ := lockAsync(context.Background(), window)
+		clock.WaitTimer()
+		clock.Advance(30 * time.Second)
+		<-done
+
+		// the second slot was used 30 seconds after the first one
+		done, _ = lockAsync(context.Background(), window)
+		clock.WaitTimer()
+		clock.Advance(30 * time.Second)
+		<-done
+	})
+
+	t.Run("slot is released on cancel", func(t *testing.T) {
+		clock := newFakeClock(time.Now())
+		window := tinkoff.SlidingWindow(clock, 1, time.Minute)
+		lock(t, window)()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done, release := lockAsync(ctx, window)
+		clock.WaitTimer()
+		cancel()
+		<-done
+		(*release)()
+
+		// the cancelled request did not use the slot, so it is available once the first request leaves the window
+		clock.Advance(time.Minute)
+		lock(t, window)()
+	})
+}
+
+type recordingLocker struct {
+	name  string
+	locks *[]string
+}
+
+func (l recordingLocker) Lock(ctx context.Context) (context.Context, context.CancelFunc) {
+	*l.locks = append(*l.locks, l.name)
+	return ctx, based.Nop
+}
+
+func TestRateLimits(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	storage := new(tinkofftest.SessionStorage)
+	require.NoError(t, storage.UpdateSession(context.Background(), server.Phone, server.NewSession()))
+
+	var locks []string
+	client := newTestClient(t, server, tinkoff.ClientParams{
+		SessionStorage: storage,
+		RateLimits: tinkoff.RateLimits{
+			"/common/":                     recordingLocker{name: "common", locks: &locks},
+			"/common/v1/accounts_light_ib": recordingLocker{name: "accounts", locks: &locks},
+			"/invest-gw/":                  recordingLocker{name: "invest", locks: &locks},
+		},
+	})
+
+	ctx := testContext(t, server)
+	_, err := client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+
+	// the more general limit is acquired before the more specific one, unrelated limits are not acquired
+	assert.Equal(t, []string{"common", "accounts"}, locks)
+
+	locks = nil
+	_, err = client.Operations(ctx, &tinkoff.OperationsIn{Account: "1", Start: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"common"}, locks)
+}
+
+func TestRateLimitRegistry(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	clock := newFakeClock(time.Now())
+	var created int
+	registry := tinkoff.NewRateLimitRegistry(func() tinkoff.RateLimits {
+		created++
+		return tinkoff.RateLimits{"/common/v1/accounts_light_ib": tinkoff.SlidingWindow(clock, 1, time.Hour)}
+	})
+
+	assert.Equal(t, registry.Get(server.Phone), registry.Get(server.Phone))
+	assert.NotSame(t, registry.Get(server.Phone)["/common/v1/accounts_light_ib"], registry.Get("+79990000000")["/common/v1/accounts_light_ib"])
+	assert.Equal(t, 2, created)
+
+	storage := new(tinkofftest.SessionStorage)
+	first := newTestClient(t, server, tinkoff.ClientParams{SessionStorage: storage, RateLimits: registry.Get(server.Phone)})
+	second := newTestClient(t, server, tinkoff.ClientParams{SessionStorage: storage, RateLimits: registry.Get(server.Phone)})
+
+	_, err := first.AccountsLightIb(testContext(t, server))
+	require.NoError(t, err)
+
+	// the second client with the same phone shares the window of the first one
+	ctx, cancel := context.WithTimeout(testContext(t, server), 50*time.Millisecond)
+	defer cancel()
+	_, err = second.AccountsLightIb(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, server.Requests("/common/v1/accounts_light_ib"))
+}
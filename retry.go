@@ -2,43 +2,230 @@ package tinkoff
 
 import (
 	"context"
+	"log/slog"
 	"math"
 	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-type retryKey struct{}
+const investPathPrefix = "/invest-gw/"
+
+// RetryAttempt описывает неудачную попытку выполнения запроса.
+type RetryAttempt struct {
+	// Path – путь эндпоинта, например "/common/v1/operations".
+	Path string
+
+	// Attempt – количество уже выполненных повторов запроса (0 для первой неудачной попытки).
+	Attempt int
+
+	// Err – ошибка, чаще всего *APIError.
+	Err error
+}
+
+// RetryPolicy определяет, нужно ли повторить запрос, и сколько ждать перед повтором.
+// Повторная авторизация при истекшей сессии выполняется клиентом независимо от RetryPolicy.
+type RetryPolicy interface {
+	Retry(attempt RetryAttempt) (time.Duration, bool)
+}
+
+// RetryPolicyFunc – функциональный адаптер для RetryPolicy.
+type RetryPolicyFunc func(attempt RetryAttempt) (time.Duration, bool)
 
-type retryStrategy struct {
-	timeout    retryTimeoutFunc
-	maxRetries int
+func (fn RetryPolicyFunc) Retry(attempt RetryAttempt) (time.Duration, bool) {
+	return fn(attempt)
 }
 
-func (rs *retryStrategy) do(ctx context.Context) (context.Context, error) {
-	retry, _ := ctx.Value(retryKey{}).(int)
-	if rs.maxRetries > 0 && retry >= rs.maxRetries {
-		return ctx, errMaxRetriesExceeded
+// NoRetryPolicy никогда не повторяет запросы.
+var NoRetryPolicy RetryPolicy = RetryPolicyFunc(func(RetryAttempt) (time.Duration, bool) { return 0, false })
+
+// DefaultRetryPolicy повторяет запросы при превышении лимита запросов (до 5 раз, начиная с задержки в 1 минуту)
+// и при HTTP-статусах, отличных от 200, для /common/v1/* (до 10 раз, начиная с задержки в 1 секунду).
+// Задержка растет экспоненциально. Если сервер прислал заголовок Retry-After, используется указанная в нем задержка.
+var DefaultRetryPolicy RetryPolicy = RetryPolicyFunc(func(attempt RetryAttempt) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(attempt.Err, &apiErr) {
+		return 0, false
+	}
+
+	var (
+		timeout    retryTimeoutFunc
+		maxRetries int
+	)
+
+	switch {
+	case IsRateLimited(apiErr):
+		timeout, maxRetries = exponentialRetryTimeout(time.Minute, 2, 0.2), 5
+	case apiErr.ResultCode == "" && apiErr.HTTPStatus != http.StatusOK && !strings.HasPrefix(attempt.Path, investPathPrefix):
+		timeout, maxRetries = exponentialRetryTimeout(time.Second, 2, 0.5), 10
+	default:
+		return 0, false
+	}
+
+	if attempt.Attempt >= maxRetries {
+		return 0, false
+	}
+
+	if apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, true
+	}
+
+	return timeout(attempt.Attempt), true
+})
+
+// TimerClock – часы, которые умеют ожидать.
+// Если ClientParams.Clock реализует этот интерфейс, клиент использует его для ожидания между повторами,
+// в ограничителях частоты запросов и в Ping (например, фейковые часы в тестах).
+// Иначе используются таймеры стандартной библиотеки.
+type TimerClock interface {
+	based.Clock
+	After(d time.Duration) <-chan time.Time
+}
+
+func sleep(ctx context.Context, clock based.Clock, timeout time.Duration) error {
+	if timeout <= 0 {
+		return ctx.Err()
+	}
+
+	var c <-chan time.Time
+	if clock, ok := clock.(TimerClock); ok {
+		c = clock.After(timeout)
+	} else {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		c = timer.C
 	}
 
 	select {
-	case <-time.After(rs.timeout(retry)):
-		return context.WithValue(ctx, retryKey{}, retry+1), nil
+	case <-c:
+		return nil
 	case <-ctx.Done():
-		return ctx, ctx.Err()
+		return ctx.Err()
 	}
 }
 
-type retryTimeoutFunc func(retry int) time.Duration
+type retryKey struct{}
 
-func exponentialRetryTimeout(base time.Duration, factor, jitter float64) retryTimeoutFunc {
-	return func(retry int) time.Duration {
-		timeout := base * time.Duration(math.Pow(factor, float64(retry)))
-		return timeout * time.Duration(1+jitter*(0.5-rand.Float64()))
+type reauthorizedKey struct{}
+
+func getRetryAttempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryKey{}).(int)
+	return attempt
+}
+
+// retry ожидает перед повтором запроса согласно RetryPolicy.
+// Возвращает false, если запрос повторять не нужно.
+func (c *Client) retry(ctx context.Context, path string, err error) (context.Context, bool, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// the request failed because the caller gave up
+		return ctx, false, ctxErr
 	}
+
+	attempt := getRetryAttempt(ctx)
+	timeout, ok := c.retryPolicy.Retry(RetryAttempt{
+		Path:    path,
+		Attempt: attempt,
+		Err:     err,
+	})
+
+	if !ok {
+		return ctx, false, nil
+	}
+
+	if c.metrics != nil {
+		c.metrics.ObserveRetry(path, attempt+1, timeout)
+	}
+
+	c.log.LogAttrs(ctx, slog.LevelWarn, "retrying request",
+		slog.String("path", path),
+		slog.Int("attempt", attempt+1),
+		slog.Duration("backoff", timeout),
+		slog.Any("error", err))
+
+	if err := sleep(ctx, c.clock, timeout); err != nil {
+		return ctx, false, err
+	}
+
+	return context.WithValue(ctx, retryKey{}, attempt+1), true, nil
+}
+
+// reauthorize выполняет повторную авторизацию не более одного раза за время выполнения запроса.
+// Возвращает false, если авторизация в рамках запроса уже выполнялась.
+// Если reason не пустой, сессия считается отклоненной сервером по этой причине.
+// staleID – сессия, с которой был выполнен запрос: если другой запрос уже заменил ее,
+// повторная авторизация не выполняется, и запрос повторяется с новой сессией.
+func (c *Client) reauthorize(ctx context.Context, reason, staleID string) (context.Context, bool, error) {
+	if reauthorized, _ := ctx.Value(reauthorizedKey{}).(bool); reauthorized {
+		return ctx, false, nil
+	}
+
+	spanCtx, end := c.startChildSpan(ctx, "tbank.reauthorize", attribute.String("tbank.reason", reason))
+	err := c.doReauthorize(spanCtx, reason, staleID)
+	end(err)
+	if err != nil {
+		return ctx, false, err
+	}
+
+	return context.WithValue(ctx, reauthorizedKey{}, true), true, nil
+}
+
+func (c *Client) doReauthorize(ctx context.Context, reason, staleID string) error {
+	ctx, cancel := c.mu.Lock(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	current, err := c.session.Get(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get sessionid")
+	}
+
+	if current != nil && current.ID != staleID {
+		return nil
+	}
+
+	if reason != "" {
+		c.observe(ctx, AuthSessionInvalidated, reason, nil)
+	}
+
+	session, err := c.authorize(ctx)
+	if err != nil {
+		return errors.Wrap(err, "authorize")
+	}
+
+	return errors.Wrap(c.session.Update(ctx, session), "store new sessionid")
 }
 
-func constantRetryTimeout(timeout time.Duration) retryTimeoutFunc {
+// parseRetryAfter разбирает заголовок Retry-After, заданный в секундах или в виде даты.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+type retryTimeoutFunc func(retry int) time.Duration
+
+func exponentialRetryTimeout(base time.Duration, factor, jitter float64) retryTimeoutFunc {
 	return func(retry int) time.Duration {
-		return timeout
+		timeout := float64(base) * math.Pow(factor, float64(retry))
+		return time.Duration(timeout * (1 + jitter*(0.5-rand.Float64())))
 	}
 }
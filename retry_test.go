@@ -0,0 +1,82 @@
+package tinkoff
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	rateLimited := &APIError{HTTPStatus: http.StatusOK, ResultCode: ResultCodeRateLimitExceeded}
+	badGateway := &APIError{HTTPStatus: http.StatusBadGateway}
+
+	for _, tc := range []struct {
+		name       string
+		path       string
+		err        error
+		maxRetries int
+		base       time.Duration
+		jitter     float64
+	}{
+		{name: "rate limit", path: "/common/v1/operations", err: rateLimited, maxRetries: 5, base: time.Minute, jitter: 0.2},
+		{name: "invest rate limit", path: "/invest-gw/ca-operations/api/v1/user/operations", err: rateLimited, maxRetries: 5, base: time.Minute, jitter: 0.2},
+		{name: "http status", path: "/common/v1/operations", err: badGateway, maxRetries: 10, base: time.Second, jitter: 0.5},
+		{name: "invest http status", path: "/invest-gw/ca-operations/api/v1/user/operations", err: badGateway},
+		{name: "other result code", path: "/common/v1/operations", err: &APIError{HTTPStatus: http.StatusOK, ResultCode: "INTERNAL_ERROR"}},
+		{name: "not an api error", path: "/common/v1/operations", err: assert.AnError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for attempt := 0; attempt <= tc.maxRetries; attempt++ {
+				timeout, ok := DefaultRetryPolicy.Retry(RetryAttempt{Path: tc.path, Attempt: attempt, Err: tc.err})
+				if attempt == tc.maxRetries {
+					assert.False(t, ok, "attempt %d", attempt)
+					continue
+				}
+
+				assert.True(t, ok, "attempt %d", attempt)
+				expected := float64(tc.base) * math.Pow(2, float64(attempt))
+				assert.InDelta(t, expected, float64(timeout), expected*tc.jitter/2, "attempt %d", attempt)
+			}
+		})
+	}
+
+	t.Run("retry after overrides backoff", func(t *testing.T) {
+		for _, err := range []*APIError{
+			{HTTPStatus: http.StatusOK, ResultCode: ResultCodeRateLimitExceeded, RetryAfter: 7 * time.Second},
+			{HTTPStatus: http.StatusServiceUnavailable, RetryAfter: 7 * time.Second},
+		} {
+			timeout, ok := DefaultRetryPolicy.Retry(RetryAttempt{Path: "/common/v1/operations", Attempt: 3, Err: err})
+			assert.True(t, ok)
+			assert.Equal(t, 7*time.Second, timeout)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		value  string
+		result time.Duration
+	}{
+		{name: "empty"},
+		{name: "seconds", value: "120", result: 2 * time.Minute},
+		{name: "zero seconds", value: "0"},
+		{name: "negative seconds", value: "-5"},
+		{name: "http date", value: now.Add(90 * time.Second).Format(http.TimeFormat), result: 90 * time.Second},
+		{name: "past http date", value: now.Add(-time.Minute).Format(http.TimeFormat)},
+		{name: "invalid", value: "soon"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header := make(http.Header)
+			if tc.value != "" {
+				header.Set("Retry-After", tc.value)
+			}
+
+			assert.Equal(t, tc.result, parseRetryAfter(header, now))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tinkoff
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultSessionIdleTimeout – оценка времени, через которое неиспользуемая сессия становится недействительной.
+const defaultSessionIdleTimeout = 30 * time.Minute
+
+type Session struct {
+	ID string
+
+	// CreatedAt – время создания сессии.
+	CreatedAt time.Time
+
+	// ValidatedAt – время последней успешной проверки сессии.
+	ValidatedAt time.Time
+
+	// ExpiresAt – время истечения сессии, если оно известно (например, из cookie при авторизации через браузер).
+	ExpiresAt time.Time
+
+	// AccessLevel – уровень доступа по результатам последней проверки сессии (например, "CLIENT").
+	AccessLevel string
+
+	// AuthFlow – способ аутентификации, с помощью которого была получена сессия.
+	AuthFlow string
+}
+
+// SessionInfo описывает текущую сессию клиента.
+type SessionInfo struct {
+	Phone string
+
+	// Active равно false, если сессии нет и для следующего запроса потребуется авторизация.
+	Active bool
+
+	CreatedAt   time.Time
+	ValidatedAt time.Time
+	AccessLevel string
+	AuthFlow    string
+
+	// ExpiresAt – ожидаемое время истечения сессии, после которого потребуется новый код подтверждения.
+	// Если время истечения сессии неизвестно, оно оценивается по времени последней проверки сессии.
+	// Пока работает Client.Ping, сессия продлевается.
+	ExpiresAt time.Time
+}
+
+// SessionInfo возвращает информацию о текущей сессии без обращения к API.
+func (c *Client) SessionInfo(ctx context.Context) (*SessionInfo, error) {
+	session, err := c.session.Get(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get session")
+	}
+
+	info := &SessionInfo{Phone: c.phone}
+	if session == nil {
+		return info, nil
+	}
+
+	info.Active = true
+	info.CreatedAt = session.CreatedAt
+	info.ValidatedAt = session.ValidatedAt
+	info.AccessLevel = session.AccessLevel
+	info.AuthFlow = session.AuthFlow
+	info.ExpiresAt = session.ExpiresAt
+	if info.ExpiresAt.IsZero() && !session.ValidatedAt.IsZero() {
+		info.ExpiresAt = session.ValidatedAt.Add(c.sessionIdleTimeout)
+	}
+
+	return info, nil
+}
+
+func authFlowName(flow AuthFlow) string {
+	if stringer, ok := flow.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	return fmt.Sprintf("%T", flow)
+}
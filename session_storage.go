@@ -0,0 +1,186 @@
+package tinkoff
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const sessionStorageNonceSize = 24
+
+// FileSessionStorage хранит сессии в JSON-файле (номер телефона -> сессия).
+// Файл записывается атомарно с правами 0600, а конкурентный доступ
+// из нескольких процессов синхронизируется блокировкой файла <Path>.lock.
+type FileSessionStorage struct {
+	// Path – путь к файлу. Родительская директория создается при необходимости.
+	Path string `validate:"required"`
+
+	// Key – 32-байтный ключ для шифрования файла (NaCl secretbox). Если не задан, файл не шифруется.
+	Key []byte
+
+	mu sync.RWMutex
+}
+
+func (s *FileSessionStorage) LoadSession(ctx context.Context, phone string) (*Session, error) {
+	if err := based.Validate(s); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	sessions, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	if session, ok := sessions[phone]; ok {
+		return &session, nil
+	}
+
+	return nil, nil
+}
+
+func (s *FileSessionStorage) UpdateSession(ctx context.Context, phone string, session *Session) error {
+	if err := based.Validate(s); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	sessions, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if session != nil {
+		sessions[phone] = *session
+	} else {
+		delete(sessions, phone)
+	}
+
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return errors.Wrap(err, "marshal sessions")
+	}
+
+	if data, err = s.seal(data); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.Path, data)
+}
+
+func (s *FileSessionStorage) lock(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return nil, errors.Wrap(err, "create parent directory")
+	}
+
+	file, err := os.OpenFile(s.Path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open lock file")
+	}
+
+	if err := lockFile(file, exclusive); err != nil {
+		_ = file.Close()
+		return nil, errors.Wrap(err, "lock file")
+	}
+
+	return func() {
+		_ = unlockFile(file)
+		_ = file.Close()
+	}, nil
+}
+
+func (s *FileSessionStorage) read() (map[string]Session, error) {
+	sessions := make(map[string]Session)
+	data, err := os.ReadFile(s.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return sessions, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "read sessions file")
+	case len(data) == 0:
+		return sessions, nil
+	}
+
+	if data, err = s.open(data); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, errors.Wrap(err, "unmarshal sessions")
+	}
+
+	return sessions, nil
+}
+
+func (s *FileSessionStorage) key() (*[32]byte, error) {
+	if len(s.Key) == 0 {
+		return nil, nil
+	}
+
+	if len(s.Key) != 32 {
+		return nil, errors.Errorf("encryption key must be 32 bytes long, got %d", len(s.Key))
+	}
+
+	var key [32]byte
+	copy(key[:], s.Key)
+	return &key, nil
+}
+
+func (s *FileSessionStorage) seal(data []byte) ([]byte, error) {
+	key, err := s.key()
+	if key == nil || err != nil {
+		return data, err
+	}
+
+	var nonce [sessionStorageNonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, errors.Wrap(err, "generate nonce")
+	}
+
+	return secretbox.Seal(nonce[:], data, &nonce, key), nil
+}
+
+func (s *FileSessionStorage) open(data []byte) ([]byte, error) {
+	key, err := s.key()
+	if key == nil || err != nil {
+		return data, err
+	}
+
+	if len(data) < sessionStorageNonceSize+secretbox.Overhead {
+		return nil, errors.New("sessions file is too short")
+	}
+
+	var nonce [sessionStorageNonceSize]byte
+	copy(nonce[:], data)
+	plain, ok := secretbox.Open(nil, data[sessionStorageNonceSize:], &nonce, key)
+	if !ok {
+		return nil, errors.New("decrypt sessions file: invalid key or corrupted file")
+	}
+
+	return plain, nil
+}
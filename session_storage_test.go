@@ -0,0 +1,114 @@
+package tinkoff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+func TestFileSessionStorage(t *testing.T) {
+	ctx := context.Background()
+	session := &tinkoff.Session{
+		ID:          "session-1",
+		CreatedAt:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ValidatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		AccessLevel: "CLIENT",
+		AuthFlow:    "api",
+	}
+
+	key := bytes.Repeat([]byte{1}, 32)
+
+	for _, tc := range []struct {
+		name string
+		key  []byte
+	}{
+		{name: "plaintext"},
+		{name: "encrypted", key: key},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sessions", "sessions.json")
+			storage := &tinkoff.FileSessionStorage{Path: path, Key: tc.key}
+
+			loaded, err := storage.LoadSession(ctx, testPhone)
+			require.NoError(t, err)
+			assert.Nil(t, loaded)
+
+			require.NoError(t, storage.UpdateSession(ctx, testPhone, session))
+			loaded, err = (&tinkoff.FileSessionStorage{Path: path, Key: tc.key}).LoadSession(ctx, testPhone)
+			require.NoError(t, err)
+			assert.Equal(t, session, loaded)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.key == nil, json.Valid(data))
+			assert.Equal(t, tc.key == nil, bytes.Contains(data, []byte(session.ID)))
+
+			if runtime.GOOS != "windows" {
+				stat, err := os.Stat(path)
+				require.NoError(t, err)
+				assert.Equal(t, os.FileMode(0o600), stat.Mode().Perm())
+			}
+
+			require.NoError(t, storage.UpdateSession(ctx, testPhone, nil))
+			loaded, err = storage.LoadSession(ctx, testPhone)
+			require.NoError(t, err)
+			assert.Nil(t, loaded)
+		})
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.json")
+		require.NoError(t, (&tinkoff.FileSessionStorage{Path: path, Key: key}).UpdateSession(ctx, testPhone, session))
+
+		for _, key := range [][]byte{bytes.Repeat([]byte{2}, 32), nil, []byte("short")} {
+			_, err := (&tinkoff.FileSessionStorage{Path: path, Key: key}).LoadSession(ctx, testPhone)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("empty path", func(t *testing.T) {
+		storage := new(tinkoff.FileSessionStorage)
+		_, err := storage.LoadSession(ctx, testPhone)
+		assert.Error(t, err)
+		assert.Error(t, storage.UpdateSession(ctx, testPhone, session))
+	})
+
+	t.Run("concurrent writers", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.json")
+		const writes = 50
+
+		// separate instances share only the file lock, as separate processes would
+		var wg sync.WaitGroup
+		for _, phone := range []string{"+79990000001", "+79990000002"} {
+			storage := &tinkoff.FileSessionStorage{Path: path, Key: key}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range writes {
+					assert.NoError(t, storage.UpdateSession(ctx, phone, &tinkoff.Session{ID: fmt.Sprintf("%s-%d", phone, i)}))
+				}
+			}()
+		}
+
+		wg.Wait()
+		storage := &tinkoff.FileSessionStorage{Path: path, Key: key}
+		for _, phone := range []string{"+79990000001", "+79990000002"} {
+			loaded, err := storage.LoadSession(ctx, phone)
+			require.NoError(t, err)
+			require.NotNil(t, loaded)
+			assert.Equal(t, fmt.Sprintf("%s-%d", phone, writes-1), loaded.ID)
+		}
+	})
+}
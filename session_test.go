@@ -0,0 +1,79 @@
+package tinkoff_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+func TestClient_SessionInfo(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{Clock: clock, SessionIdleTimeout: 10 * time.Minute})
+
+	info, err := client.SessionInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &tinkoff.SessionInfo{Phone: server.Phone}, info)
+
+	_, err = client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+
+	info, err = client.SessionInfo(ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Active)
+	assert.Equal(t, start, info.CreatedAt)
+	assert.Equal(t, start, info.ValidatedAt)
+	assert.Equal(t, "api", info.AuthFlow)
+	assert.Equal(t, start.Add(10*time.Minute), info.ExpiresAt)
+
+	ping := func() {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			client.Ping(ctx)
+		}()
+
+		// the first ping is complete once Ping waits for the next one
+		clock.WaitTimer()
+		cancel()
+		<-done
+	}
+
+	t.Run("successful ping extends session", func(t *testing.T) {
+		clock.Advance(5 * time.Minute)
+		ping()
+
+		info, err := client.SessionInfo(ctx)
+		require.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, start, info.CreatedAt)
+		assert.Equal(t, start.Add(5*time.Minute), info.ValidatedAt)
+		assert.Equal(t, "CLIENT", info.AccessLevel)
+		assert.Equal(t, start.Add(15*time.Minute), info.ExpiresAt)
+	})
+
+	t.Run("expired session is reset", func(t *testing.T) {
+		storage := new(tinkofftest.SessionStorage)
+		session := server.NewSession()
+		require.NoError(t, storage.UpdateSession(ctx, server.Phone, session))
+		client = newTestClient(t, server, tinkoff.ClientParams{Clock: clock, SessionStorage: storage})
+
+		server.ExpireSession(session.ID)
+		ping()
+
+		info, err := client.SessionInfo(ctx)
+		require.NoError(t, err)
+		assert.False(t, info.Active)
+	})
+}
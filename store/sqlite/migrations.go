@@ -0,0 +1,237 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// migrations содержит изменения схемы базы данных.
+// Уже примененные миграции изменять нельзя – новые изменения добавляются в конец списка.
+var migrations = []string{
+	`
+	create table accounts (
+		id text primary key,
+		name text not null,
+		account_type text not null,
+		currency text,
+		money_amount real,
+		credit_limit real,
+		debt_amount real,
+		status text,
+		hidden integer not null,
+		creation_date integer
+	);
+
+	create table cards (
+		id text primary key,
+		account_id text not null references accounts (id) on delete cascade,
+		value text not null,
+		name text not null,
+		status text not null,
+		payment_system text not null,
+		expiration integer not null,
+		is_virtual integer not null,
+		is_primary integer not null,
+		frozen integer not null
+	);
+
+	create table operations (
+		id text primary key,
+		account text not null,
+		type text not null,
+		status text not null,
+		description text not null,
+		operation_time integer not null,
+		debiting_time integer,
+		amount real not null,
+		amount_currency text not null,
+		account_amount real not null,
+		account_amount_currency text not null,
+		cashback real not null,
+		cashback_amount real not null,
+		mcc integer not null,
+		mcc_string text not null,
+		category_id text not null,
+		category_name text not null,
+		spending_category_id text not null,
+		spending_category_name text not null,
+		subgroup_id text,
+		subgroup_name text,
+		card text,
+		card_number text,
+		brand_id text,
+		brand_name text,
+		merchant_name text,
+		merchant_country text,
+		merchant_city text,
+		merchant_address text,
+		has_shopping_receipt integer,
+		message text
+	);
+
+	create index operations_account_time on operations (account, operation_time);
+
+	create table loyalty_bonuses (
+		operation_id text not null references operations (id) on delete cascade,
+		idx integer not null,
+		description text not null,
+		icon text not null,
+		loyalty_type text not null,
+		compensation_type text not null,
+		amount real not null,
+		loyalty_program_id text not null,
+		loyalty text not null,
+		name text not null,
+		loyalty_points_name text not null,
+		primary key (operation_id, idx)
+	);
+
+	create table additional_infos (
+		operation_id text not null references operations (id) on delete cascade,
+		idx integer not null,
+		field_name text not null,
+		field_value text not null,
+		primary key (operation_id, idx)
+	);
+
+	create table receipts (
+		operation_id text primary key,
+		operation_date_time integer not null,
+		date_time integer not null,
+		retail_place text,
+		retail_place_address text,
+		operation_type integer not null,
+		total_sum real not null,
+		cash_total_sum real not null,
+		ecash_total_sum real not null,
+		user_inn text not null,
+		user text,
+		operator text,
+		kkt_reg_id text not null,
+		fiscal_drive_number text not null,
+		fiscal_document_number integer not null,
+		fiscal_sign integer not null,
+		shift_number integer not null,
+		request_number integer not null,
+		taxation_type integer not null
+	);
+
+	create table receipt_items (
+		operation_id text not null references receipts (operation_id) on delete cascade,
+		idx integer not null,
+		name text not null,
+		price real not null,
+		sum real not null,
+		quantity real not null,
+		nds_rate integer,
+		nds integer,
+		brand_id integer,
+		good_id integer,
+		primary key (operation_id, idx)
+	);
+
+	create table invest_operations (
+		internal_id text primary key,
+		id text,
+		broker_account_id text not null,
+		account_name text not null,
+		type text not null,
+		status text not null,
+		description text not null,
+		date integer not null,
+		payment real not null,
+		payment_currency text not null,
+		ticker text,
+		name text,
+		isin text,
+		instrument_type text,
+		instrument_uid text,
+		quantity integer,
+		price real,
+		price_currency text,
+		commission real,
+		commission_currency text,
+		yield real,
+		yield_currency text,
+		parent_operation_id text,
+		cursor text not null
+	);
+
+	create index invest_operations_account_date on invest_operations (broker_account_id, date);
+
+	create table trades (
+		invest_operation_id text not null references invest_operations (internal_id) on delete cascade,
+		num text not null,
+		date integer not null,
+		price real not null,
+		price_currency text not null,
+		quantity integer not null,
+		primary key (invest_operation_id, num)
+	);
+
+	create table invest_candles (
+		ticker text not null,
+		resolution text not null,
+		date integer not null,
+		o real not null,
+		c real not null,
+		h real not null,
+		l real not null,
+		v real not null,
+		primary key (ticker, resolution, date)
+	);
+
+	create table sync_checkpoints (
+		kind text not null,
+		account text not null,
+		data text not null,
+		primary key (kind, account)
+	);
+	`,
+	`
+	update invest_candles set date = date * 1000;
+	`,
+}
+
+// Migrate применяет к базе данных недостающие миграции.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `create table if not exists schema_migrations (version integer primary key)`); err != nil {
+		return errors.Wrap(err, "create schema_migrations")
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `select coalesce(max(version), 0) from schema_migrations`).Scan(&version); err != nil {
+		return errors.Wrap(err, "select schema version")
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := inTx(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return err
+			}
+
+			_, err := tx.ExecContext(ctx, `insert into schema_migrations (version) values (?)`, i+1)
+			return err
+		}); err != nil {
+			return errors.Wrapf(err, "apply migration %d", i+1)
+		}
+	}
+
+	return nil
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "commit transaction")
+}
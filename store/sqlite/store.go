@@ -0,0 +1,371 @@
+// Package sqlite хранит счета, операции, кассовые чеки и данные по брокерским счетам в базе данных SQLite.
+// Используется драйвер modernc.org/sqlite, не требующий cgo.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+const foreignKeysPragma = "_pragma=foreign_keys(1)"
+
+// Open открывает базу данных по пути или DSN и применяет миграции.
+// Внешние ключи включаются для каждого соединения параметром DSN _pragma=foreign_keys(1).
+func Open(ctx context.Context, dsn string) (*Store, error) {
+	db, err := sql.Open("sqlite", withForeignKeys(dsn))
+	if err != nil {
+		return nil, errors.Wrap(err, "open database")
+	}
+
+	// sqlite does not support concurrent writes
+	db.SetMaxOpenConns(1)
+
+	store, err := New(ctx, db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// New создает Store поверх открытой базы данных и применяет миграции.
+// Внешние ключи должны быть включены для всех соединений db, например параметром DSN _pragma=foreign_keys(1):
+// pragma foreign_keys действует только на то соединение, в котором выполнена.
+func New(ctx context.Context, db *sql.DB) (*Store, error) {
+	var foreignKeys bool
+	if err := db.QueryRowContext(ctx, `pragma foreign_keys`).Scan(&foreignKeys); err != nil {
+		return nil, errors.Wrap(err, "check foreign keys")
+	}
+
+	if !foreignKeys {
+		return nil, errors.New("foreign keys are disabled, open database with " + foreignKeysPragma)
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		return nil, errors.Wrap(err, "migrate")
+	}
+
+	return &Store{db: db}, nil
+}
+
+func withForeignKeys(dsn string) string {
+	if strings.Contains(dsn, "_pragma=foreign_keys") {
+		return dsn
+	}
+
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + foreignKeysPragma
+	}
+
+	return dsn + "?" + foreignKeysPragma
+}
+
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) UpsertAccounts(ctx context.Context, accounts ...tinkoff.Account) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, account := range accounts {
+			if err := upsertAccount(ctx, tx, account); err != nil {
+				return errors.Wrapf(err, "upsert account %s", account.Id)
+			}
+		}
+
+		return nil
+	})
+}
+
+func upsertAccount(ctx context.Context, tx *sql.Tx, account tinkoff.Account) error {
+	var currency *string
+	if account.Currency != nil {
+		currency = &account.Currency.Name
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		insert or replace into accounts (
+			id, name, account_type, currency, money_amount, credit_limit, debt_amount, status, hidden, creation_date
+		) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		account.Id, account.Name, account.AccountType, currency,
+		moneyValue(account.MoneyAmount), moneyValue(account.CreditLimit), moneyValue(account.DebtAmount),
+		account.Status, account.Hidden, millis(account.CreationDate),
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from cards where account_id = ?`, account.Id); err != nil {
+		return errors.Wrap(err, "delete cards")
+	}
+
+	for _, card := range account.Cards {
+		if _, err := tx.ExecContext(ctx, `
+			insert or replace into cards (
+				id, account_id, value, name, status, payment_system, expiration, is_virtual, is_primary, frozen
+			) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			card.Id, account.Id, card.Value, card.Name, card.Status, card.PaymentSystem,
+			card.Expiration.Time().UnixMilli(), card.IsVirtual, card.Primary, card.FrozenCard,
+		); err != nil {
+			return errors.Wrapf(err, "insert card %s", card.Id)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) UpsertOperations(ctx context.Context, operations ...tinkoff.Operation) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, operation := range operations {
+			if err := upsertOperation(ctx, tx, operation); err != nil {
+				return errors.Wrapf(err, "upsert operation %s", operation.Id)
+			}
+		}
+
+		return nil
+	})
+}
+
+func upsertOperation(ctx context.Context, tx *sql.Tx, operation tinkoff.Operation) error {
+	var subgroupID, subgroupName *string
+	if operation.Subgroup != nil {
+		subgroupID, subgroupName = &operation.Subgroup.Id, &operation.Subgroup.Name
+	}
+
+	var brandID, brandName *string
+	if operation.Brand != nil {
+		brandID, brandName = &operation.Brand.Id, &operation.Brand.Name
+	}
+
+	var merchantName, merchantCountry, merchantCity, merchantAddress *string
+	if operation.Merchant != nil {
+		merchantName = &operation.Merchant.Name
+		if region := operation.Merchant.Region; region != nil {
+			merchantCountry, merchantCity, merchantAddress = region.Country, region.City, region.Address
+		}
+	}
+
+	// children are removed by cascade
+	if _, err := tx.ExecContext(ctx, `delete from operations where id = ?`, operation.Id); err != nil {
+		return errors.Wrap(err, "delete operation")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		insert into operations (
+			id, account, type, status, description, operation_time, debiting_time,
+			amount, amount_currency, account_amount, account_amount_currency, cashback, cashback_amount,
+			mcc, mcc_string, category_id, category_name, spending_category_id, spending_category_name,
+			subgroup_id, subgroup_name, card, card_number, brand_id, brand_name,
+			merchant_name, merchant_country, merchant_city, merchant_address, has_shopping_receipt, message
+		) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		operation.Id, operation.Account, operation.Type, operation.Status, operation.Description,
+		operation.OperationTime.Time().UnixMilli(), millis(operation.DebitingTime),
+		operation.Amount.Value, operation.Amount.Currency.Name,
+		operation.AccountAmount.Value, operation.AccountAmount.Currency.Name,
+		operation.Cashback, operation.CashbackAmount.Value,
+		operation.Mcc, operation.MccString, operation.Category.Id, operation.Category.Name,
+		operation.SpendingCategory.Id, operation.SpendingCategory.Name,
+		subgroupID, subgroupName, operation.Card, operation.CardNumber, brandID, brandName,
+		merchantName, merchantCountry, merchantCity, merchantAddress, operation.HasShoppingReceipt, operation.Message,
+	); err != nil {
+		return err
+	}
+
+	for i, bonus := range operation.LoyaltyBonus {
+		if _, err := tx.ExecContext(ctx, `
+			insert into loyalty_bonuses (
+				operation_id, idx, description, icon, loyalty_type, compensation_type,
+				amount, loyalty_program_id, loyalty, name, loyalty_points_name
+			) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			operation.Id, i, bonus.Description, bonus.Icon, bonus.LoyaltyType, bonus.CompensationType,
+			bonus.Amount.Value, bonus.Amount.LoyaltyProgramId, bonus.Amount.Loyalty, bonus.Amount.Name,
+			bonus.Amount.LoyaltyPointsName,
+		); err != nil {
+			return errors.Wrap(err, "insert loyalty bonus")
+		}
+	}
+
+	for i, info := range operation.AdditionalInfo {
+		if _, err := tx.ExecContext(ctx, `
+			insert into additional_infos (operation_id, idx, field_name, field_value) values (?, ?, ?, ?)`,
+			operation.Id, i, info.FieldName, info.FieldValue,
+		); err != nil {
+			return errors.Wrap(err, "insert additional info")
+		}
+	}
+
+	return nil
+}
+
+// DeleteOperation удаляет операцию вместе с ее кассовым чеком.
+func (s *Store) DeleteOperation(ctx context.Context, id string) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `delete from receipts where operation_id = ?`, id); err != nil {
+			return errors.Wrap(err, "delete receipt")
+		}
+
+		_, err := tx.ExecContext(ctx, `delete from operations where id = ?`, id)
+		return errors.Wrap(err, "delete operation")
+	})
+}
+
+func (s *Store) UpsertReceipt(ctx context.Context, receipt *tinkoff.ShoppingReceiptOut) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := upsertReceipt(ctx, tx, receipt); err != nil {
+			return errors.Wrapf(err, "upsert receipt %s", receipt.OperationId)
+		}
+
+		return nil
+	})
+}
+
+func upsertReceipt(ctx context.Context, tx *sql.Tx, out *tinkoff.ShoppingReceiptOut) error {
+	if _, err := tx.ExecContext(ctx, `delete from receipts where operation_id = ?`, out.OperationId); err != nil {
+		return errors.Wrap(err, "delete receipt")
+	}
+
+	receipt := out.Receipt
+	if _, err := tx.ExecContext(ctx, `
+		insert into receipts (
+			operation_id, operation_date_time, date_time, retail_place, retail_place_address, operation_type,
+			total_sum, cash_total_sum, ecash_total_sum, user_inn, user, operator, kkt_reg_id,
+			fiscal_drive_number, fiscal_document_number, fiscal_sign, shift_number, request_number, taxation_type
+		) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		out.OperationId, out.OperationDateTime.Time().UnixMilli(), receipt.DateTime.Time().UnixMilli(),
+		receipt.RetailPlace, receipt.RetailPlaceAddress, receipt.OperationType,
+		receipt.TotalSum, receipt.CashTotalSum, receipt.EcashTotalSum, receipt.UserInn, receipt.User, receipt.Operator,
+		receipt.KktRegId, receipt.FiscalDriveNumberString, receipt.FiscalDocumentNumber, receipt.FiscalSign,
+		receipt.ShiftNumber, receipt.RequestNumber, receipt.TaxationType,
+	); err != nil {
+		return err
+	}
+
+	for i, item := range receipt.Items {
+		if _, err := tx.ExecContext(ctx, `
+			insert into receipt_items (
+				operation_id, idx, name, price, sum, quantity, nds_rate, nds, brand_id, good_id
+			) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			out.OperationId, i, item.Name, item.Price, item.Sum, item.Quantity, item.NdsRate, item.Nds,
+			item.BrandId, item.GoodId,
+		); err != nil {
+			return errors.Wrap(err, "insert receipt item")
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) UpsertInvestOperations(ctx context.Context, operations ...tinkoff.InvestOperation) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, operation := range operations {
+			if err := upsertInvestOperation(ctx, tx, operation); err != nil {
+				return errors.Wrapf(err, "upsert invest operation %s", operation.InternalId)
+			}
+		}
+
+		return nil
+	})
+}
+
+func upsertInvestOperation(ctx context.Context, tx *sql.Tx, operation tinkoff.InvestOperation) error {
+	if _, err := tx.ExecContext(ctx, `delete from invest_operations where internal_id = ?`, operation.InternalId); err != nil {
+		return errors.Wrap(err, "delete invest operation")
+	}
+
+	price, priceCurrency := investAmount(operation.Price)
+	commission, commissionCurrency := investAmount(operation.Commission)
+	yield, yieldCurrency := investAmount(operation.Yield)
+	if _, err := tx.ExecContext(ctx, `
+		insert into invest_operations (
+			internal_id, id, broker_account_id, account_name, type, status, description, date,
+			payment, payment_currency, ticker, name, isin, instrument_type, instrument_uid, quantity,
+			price, price_currency, commission, commission_currency, yield, yield_currency, parent_operation_id, cursor
+		) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		operation.InternalId, operation.Id, operation.BrokerAccountId, operation.AccountName, operation.Type,
+		operation.Status, operation.Description, operation.Date.Time().UnixMilli(),
+		operation.Payment.Value, operation.Payment.Currency, operation.Ticker, operation.Name, operation.Isin,
+		operation.InstrumentType, operation.InstrumentUid, operation.Quantity,
+		price, priceCurrency, commission, commissionCurrency, yield, yieldCurrency,
+		operation.ParentOperationId, operation.Cursor,
+	); err != nil {
+		return err
+	}
+
+	if operation.TradesInfo == nil {
+		return nil
+	}
+
+	for _, trade := range operation.TradesInfo.Trades {
+		if _, err := tx.ExecContext(ctx, `
+			insert or replace into trades (
+				invest_operation_id, num, date, price, price_currency, quantity
+			) values (?, ?, ?, ?, ?, ?)`,
+			operation.InternalId, trade.Num, trade.Date.Time().UnixMilli(),
+			trade.Price.Value, trade.Price.Currency, trade.Quantity,
+		); err != nil {
+			return errors.Wrapf(err, "insert trade %s", trade.Num)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteInvestOperation(ctx context.Context, internalID string) error {
+	_, err := s.db.ExecContext(ctx, `delete from invest_operations where internal_id = ?`, internalID)
+	return errors.Wrap(err, "delete invest operation")
+}
+
+func (s *Store) UpsertInvestCandles(ctx context.Context, ticker, resolution string, candles ...tinkoff.InvestCandle) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, candle := range candles {
+			if _, err := tx.ExecContext(ctx, `
+				insert or replace into invest_candles (ticker, resolution, date, o, c, h, l, v)
+				values (?, ?, ?, ?, ?, ?, ?, ?)`,
+				ticker, resolution, candle.Date.Time().UnixMilli(), candle.O, candle.C, candle.H, candle.L, candle.V,
+			); err != nil {
+				return errors.Wrapf(err, "upsert candle %s", candle.Date.Time())
+			}
+		}
+
+		return nil
+	})
+}
+
+func moneyValue(amount *tinkoff.MoneyAmount) *float64 {
+	if amount == nil {
+		return nil
+	}
+
+	return &amount.Value
+}
+
+func investAmount(amount *tinkoff.InvestAmount) (*float64, *string) {
+	if amount == nil {
+		return nil, nil
+	}
+
+	return &amount.Value, &amount.Currency
+}
+
+func millis(ms *tinkoff.Milliseconds) *int64 {
+	if ms == nil {
+		return nil
+	}
+
+	value := time.Time(*ms).UnixMilli()
+	return &value
+}
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/sync"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func count(t *testing.T, store *Store, table string) int {
+	t.Helper()
+	var count int
+	require.NoError(t, store.DB().QueryRow(`select count(*) from `+table).Scan(&count))
+	return count
+}
+
+func TestWithForeignKeys(t *testing.T) {
+	for _, tc := range []struct {
+		dsn, expected string
+	}{
+		{"test.db", "test.db?_pragma=foreign_keys(1)"},
+		{"file:test.db?mode=rwc", "file:test.db?mode=rwc&_pragma=foreign_keys(1)"},
+		{"test.db?_pragma=foreign_keys(0)", "test.db?_pragma=foreign_keys(0)"},
+	} {
+		assert.Equal(t, tc.expected, withForeignKeys(tc.dsn))
+	}
+}
+
+func TestNew_RequiresForeignKeys(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = New(context.Background(), db)
+	assert.Error(t, err)
+}
+
+func TestStore_ForeignKeysOnEveryConnection(t *testing.T) {
+	store := openTestStore(t)
+	store.DB().SetMaxOpenConns(4)
+	store.DB().SetConnMaxLifetime(time.Nanosecond)
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		conn, err := store.DB().Conn(ctx)
+		require.NoError(t, err)
+
+		var foreignKeys bool
+		require.NoError(t, conn.QueryRowContext(ctx, `pragma foreign_keys`).Scan(&foreignKeys))
+		assert.True(t, foreignKeys)
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestStore_HandleDeletedOperation(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	now := time.Now()
+	operation := tinkoff.Operation{
+		Id:            "1",
+		Account:       "account",
+		OperationTime: tinkoff.Milliseconds(now),
+		AdditionalInfo: []tinkoff.AdditionalInfo{
+			{FieldName: "name", FieldValue: "value"},
+		},
+	}
+
+	receipt := &tinkoff.ShoppingReceiptOut{
+		OperationId:       "1",
+		OperationDateTime: tinkoff.Milliseconds(now),
+		Receipt: tinkoff.Receipt{
+			DateTime: tinkoff.ReceiptDateTime(now),
+			Items:    []tinkoff.ReceiptItem{{Name: "item", Price: 1, Sum: 1, Quantity: 1}},
+		},
+	}
+
+	require.NoError(t, store.HandleEvent(ctx, sync.Event{
+		Type:      sync.Inserted,
+		Kind:      sync.Operations,
+		ID:        operation.Id,
+		Operation: &operation,
+		Receipt:   receipt,
+	}))
+
+	assert.Equal(t, 1, count(t, store, "operations"))
+	assert.Equal(t, 1, count(t, store, "additional_infos"))
+	assert.Equal(t, 1, count(t, store, "receipts"))
+	assert.Equal(t, 1, count(t, store, "receipt_items"))
+
+	require.NoError(t, store.HandleEvent(ctx, sync.Event{
+		Type: sync.Deleted,
+		Kind: sync.Operations,
+		ID:   operation.Id,
+	}))
+
+	for _, table := range []string{"operations", "additional_infos", "receipts", "receipt_items"} {
+		assert.Equal(t, 0, count(t, store, table), table)
+	}
+}
+
+func TestStore_UpsertInvestCandles(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	date := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, store.UpsertInvestCandles(ctx, "TCSG", "D", tinkoff.InvestCandle{
+		O: 1, C: 2, H: 3, L: 0.5, V: 100,
+		Date: tinkoff.InvestCandleDate(date),
+	}))
+
+	var stored int64
+	require.NoError(t, store.DB().QueryRow(`select date from invest_candles`).Scan(&stored))
+	assert.Equal(t, date.UnixMilli(), stored)
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/jfk9w-go/tbank-api/sync"
+)
+
+// LoadCheckpoint реализует sync.Checkpointer.
+func (s *Store) LoadCheckpoint(ctx context.Context, kind sync.Kind, account string) (*sync.Checkpoint, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `select data from sync_checkpoints where kind = ? and account = ?`, kind, account).Scan(&data)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "select checkpoint")
+	}
+
+	var checkpoint sync.Checkpoint
+	if err := json.Unmarshal([]byte(data), &checkpoint); err != nil {
+		return nil, errors.Wrap(err, "decode checkpoint")
+	}
+
+	return &checkpoint, nil
+}
+
+// UpdateCheckpoint реализует sync.Checkpointer.
+func (s *Store) UpdateCheckpoint(ctx context.Context, kind sync.Kind, account string, checkpoint *sync.Checkpoint) error {
+	if checkpoint == nil {
+		_, err := s.db.ExecContext(ctx, `delete from sync_checkpoints where kind = ? and account = ?`, kind, account)
+		return errors.Wrap(err, "delete checkpoint")
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.Wrap(err, "encode checkpoint")
+	}
+
+	_, err = s.db.ExecContext(ctx, `insert or replace into sync_checkpoints (kind, account, data) values (?, ?, ?)`, kind, account, string(data))
+	return errors.Wrap(err, "upsert checkpoint")
+}
+
+// HandleEvent реализует sync.Sink.
+func (s *Store) HandleEvent(ctx context.Context, event sync.Event) error {
+	switch {
+	case event.Type == sync.Deleted && event.Kind == sync.Operations:
+		return s.DeleteOperation(ctx, event.ID)
+
+	case event.Type == sync.Deleted && event.Kind == sync.InvestOperations:
+		_, err := s.db.ExecContext(ctx, `delete from invest_operations where internal_id = ?1 or id = ?1`, event.ID)
+		return errors.Wrap(err, "delete invest operation")
+
+	case event.Operation != nil:
+		if err := s.UpsertOperations(ctx, *event.Operation); err != nil {
+			return err
+		}
+
+		if event.Receipt != nil {
+			return s.UpsertReceipt(ctx, event.Receipt)
+		}
+
+		return nil
+
+	case event.InvestOperation != nil:
+		return s.UpsertInvestOperations(ctx, *event.InvestOperation)
+
+	default:
+		return errors.Errorf("unsupported event %s for %s", event.Type, event.Kind)
+	}
+}
@@ -0,0 +1,456 @@
+// Package sync реализует инкрементальную синхронизацию операций по счетам и брокерским счетам.
+// Состояние синхронизации для каждого счета хранится в Checkpointer, а изменения передаются в Sink
+// в виде событий о добавлении, изменении и удалении операций.
+//
+// Доставка событий выполняется по принципу "как минимум один раз": если Sink вернул ошибку,
+// часть событий может быть повторно отправлена при следующей синхронизации.
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"iter"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/jfk9w-go/based"
+	"github.com/pkg/errors"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+const (
+	defaultLookback = 7 * 24 * time.Hour
+	defaultHistory  = 365 * 24 * time.Hour
+
+	// saveEvery задает количество событий, после которого промежуточное состояние сохраняется в Checkpointer.
+	saveEvery = 100
+)
+
+// Kind обозначает тип синхронизируемых данных.
+type Kind string
+
+const (
+	Operations       Kind = "operations"
+	InvestOperations Kind = "invest_operations"
+)
+
+// Fingerprint описывает известную операцию внутри окна повторной проверки.
+type Fingerprint struct {
+	Time time.Time `json:"time"`
+	Hash string    `json:"hash"`
+}
+
+// Checkpoint – состояние синхронизации счета.
+type Checkpoint struct {
+	// Time – время самой поздней синхронизированной операции.
+	Time time.Time `json:"time"`
+
+	// Cursor – курсор, с которого нужно продолжить прерванную синхронизацию операций по брокерскому счету.
+	Cursor string `json:"cursor,omitempty"`
+
+	// To – конец периода прерванной синхронизации. Курсор действителен только для того же периода,
+	// поэтому синхронизация продолжается с Cursor до To, а не до текущего времени.
+	To time.Time `json:"to"`
+
+	// Known содержит операции, время которых попадает в окно повторной проверки.
+	Known map[string]Fingerprint `json:"known,omitempty"`
+}
+
+// Checkpointer хранит состояние синхронизации.
+type Checkpointer interface {
+	LoadCheckpoint(ctx context.Context, kind Kind, account string) (*Checkpoint, error)
+	UpdateCheckpoint(ctx context.Context, kind Kind, account string, checkpoint *Checkpoint) error
+}
+
+// EventType обозначает тип изменения.
+type EventType string
+
+const (
+	Inserted EventType = "inserted"
+	Updated  EventType = "updated"
+	Deleted  EventType = "deleted"
+)
+
+// Event описывает изменение операции.
+// Для событий Deleted заполняется только ID, а Operation и InvestOperation равны nil.
+type Event struct {
+	Type            EventType
+	Kind            Kind
+	Account         string
+	ID              string
+	Operation       *tinkoff.Operation
+	Receipt         *tinkoff.ShoppingReceiptOut
+	InvestOperation *tinkoff.InvestOperation
+}
+
+// Sink получает события синхронизации.
+type Sink interface {
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// SinkFunc – функциональный адаптер для Sink.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (fn SinkFunc) HandleEvent(ctx context.Context, event Event) error {
+	return fn(ctx, event)
+}
+
+// Client описывает методы tinkoff.Client, которые используются при синхронизации.
+type Client interface {
+	AccountsLightIb(ctx context.Context) (tinkoff.AccountsLightIbOut, error)
+	OperationsIter(ctx context.Context, account string, from, to time.Time) iter.Seq2[tinkoff.Operation, error]
+	ShoppingReceipt(ctx context.Context, in *tinkoff.ShoppingReceiptIn) (*tinkoff.ShoppingReceiptOut, error)
+	InvestAccounts(ctx context.Context, in *tinkoff.InvestAccountsIn) (*tinkoff.InvestAccountsOut, error)
+	InvestOperationsIter(ctx context.Context, in *tinkoff.InvestOperationsIn, types ...string) iter.Seq2[tinkoff.InvestOperation, error]
+}
+
+type Params struct {
+	Clock        based.Clock  `validate:"required"`
+	Client       Client       `validate:"required"`
+	Checkpointer Checkpointer `validate:"required"`
+	Sink         Sink         `validate:"required"`
+
+	// Lookback – окно повторной проверки операций перед временем последней синхронизации.
+	// Операции в этом окне запрашиваются повторно, чтобы обнаружить изменения (например, смену статуса).
+	// По умолчанию 7 дней.
+	Lookback time.Duration
+
+	// History – глубина первоначальной синхронизации. По умолчанию 1 год.
+	History time.Duration
+
+	// Receipts включает загрузку кассовых чеков для добавленных и измененных операций.
+	Receipts bool
+}
+
+type Syncer struct {
+	clock        based.Clock
+	client       Client
+	checkpointer Checkpointer
+	sink         Sink
+	lookback     time.Duration
+	history      time.Duration
+	receipts     bool
+}
+
+func New(params Params) (*Syncer, error) {
+	if err := based.Validate(params); err != nil {
+		return nil, err
+	}
+
+	lookback := params.Lookback
+	if lookback <= 0 {
+		lookback = defaultLookback
+	}
+
+	history := params.History
+	if history <= 0 {
+		history = defaultHistory
+	}
+
+	return &Syncer{
+		clock:        params.Clock,
+		client:       params.Client,
+		checkpointer: params.Checkpointer,
+		sink:         params.Sink,
+		lookback:     lookback,
+		history:      history,
+		receipts:     params.Receipts,
+	}, nil
+}
+
+// All синхронизирует операции по всем счетам и брокерским счетам.
+func (s *Syncer) All(ctx context.Context) error {
+	accounts, err := s.client.AccountsLightIb(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get accounts")
+	}
+
+	for _, account := range accounts {
+		if account.AccountType == "Telecom" || account.AccountType == "ExternalAccount" {
+			continue
+		}
+
+		if err := s.Operations(ctx, account.Id); err != nil {
+			return errors.Wrapf(err, "sync account %s", account.Id)
+		}
+	}
+
+	investAccounts, err := s.client.InvestAccounts(ctx, &tinkoff.InvestAccountsIn{Currency: "RUB"})
+	if err != nil {
+		return errors.Wrap(err, "get invest accounts")
+	}
+
+	for _, account := range investAccounts.Accounts.List {
+		if err := s.InvestOperations(ctx, account.BrokerAccountId); err != nil {
+			return errors.Wrapf(err, "sync invest account %s", account.BrokerAccountId)
+		}
+	}
+
+	return nil
+}
+
+// Operations синхронизирует операции по счету account.
+func (s *Syncer) Operations(ctx context.Context, account string) error {
+	state, err := s.load(ctx, Operations, account)
+	if err != nil {
+		return err
+	}
+
+	state.to = s.clock.Now()
+	for operation, err := range s.client.OperationsIter(ctx, account, state.from, state.to) {
+		if err != nil {
+			return state.fail(ctx, err)
+		}
+
+		id := operation.Id
+		eventType, fingerprint, ok, err := state.observe(id, operation.OperationTime.Time(), operation)
+		if err != nil {
+			return state.fail(ctx, err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		event := Event{
+			Type:      eventType,
+			Kind:      Operations,
+			Account:   account,
+			ID:        id,
+			Operation: &operation,
+		}
+
+		if s.receipts && pointer.Get(operation.HasShoppingReceipt) {
+			event.Receipt, err = s.client.ShoppingReceipt(ctx, &tinkoff.ShoppingReceiptIn{OperationId: id})
+			if err != nil && !errors.Is(err, tinkoff.ErrNoDataFound) {
+				return state.fail(ctx, errors.Wrapf(err, "get shopping receipt for %s", id))
+			}
+		}
+
+		if err := state.emit(ctx, event, fingerprint); err != nil {
+			return err
+		}
+	}
+
+	return state.complete(ctx, "")
+}
+
+// InvestOperations синхронизирует операции по брокерскому счету brokerAccountId.
+func (s *Syncer) InvestOperations(ctx context.Context, brokerAccountId string) error {
+	state, err := s.load(ctx, InvestOperations, brokerAccountId)
+	if err != nil {
+		return err
+	}
+
+	state.to = s.clock.Now()
+	if state.checkpoint.Cursor != "" {
+		if state.checkpoint.To.IsZero() {
+			// the period of the interrupted pass is unknown, so the cursor can not be reused
+			state.checkpoint.Cursor = ""
+		} else {
+			state.to = state.checkpoint.To
+		}
+	}
+
+	in := &tinkoff.InvestOperationsIn{
+		From:            state.from,
+		To:              state.to,
+		BrokerAccountId: brokerAccountId,
+		Cursor:          state.checkpoint.Cursor,
+	}
+
+	for operation, err := range s.client.InvestOperationsIter(ctx, in) {
+		if err != nil {
+			return state.fail(ctx, err)
+		}
+
+		id := investOperationID(operation)
+		eventType, fingerprint, ok, err := state.observe(id, operation.Date.Time(), operation)
+		if err != nil {
+			return state.fail(ctx, err)
+		}
+
+		state.cursor = operation.Cursor
+		if !ok {
+			continue
+		}
+
+		if err := state.emit(ctx, Event{
+			Type:            eventType,
+			Kind:            InvestOperations,
+			Account:         brokerAccountId,
+			ID:              id,
+			InvestOperation: &operation,
+		}, fingerprint); err != nil {
+			return err
+		}
+	}
+
+	return state.complete(ctx, in.Cursor)
+}
+
+func investOperationID(operation tinkoff.InvestOperation) string {
+	if operation.Id != nil {
+		return *operation.Id
+	}
+
+	return operation.InternalId
+}
+
+type syncState struct {
+	*Syncer
+	kind       Kind
+	account    string
+	checkpoint Checkpoint
+	from       time.Time
+	to         time.Time
+	seen       map[string]bool
+	cursor     string
+	pending    int
+}
+
+func (s *Syncer) load(ctx context.Context, kind Kind, account string) (*syncState, error) {
+	checkpoint, err := s.checkpointer.LoadCheckpoint(ctx, kind, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "load checkpoint")
+	}
+
+	state := &syncState{
+		Syncer:  s,
+		kind:    kind,
+		account: account,
+		seen:    make(map[string]bool),
+	}
+
+	if checkpoint != nil {
+		state.checkpoint = *checkpoint
+		state.from = checkpoint.Time.Add(-s.lookback)
+	} else {
+		state.from = s.clock.Now().Add(-s.history)
+	}
+
+	if state.checkpoint.Known == nil {
+		state.checkpoint.Known = make(map[string]Fingerprint)
+	}
+
+	return state, nil
+}
+
+// observe регистрирует операцию и возвращает тип события, если операция новая или изменилась.
+func (s *syncState) observe(id string, at time.Time, value any) (EventType, Fingerprint, bool, error) {
+	s.seen[id] = true
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", Fingerprint{}, false, errors.Wrapf(err, "marshal operation %s", id)
+	}
+
+	hash := sha256.Sum256(data)
+	fingerprint := Fingerprint{
+		Time: at,
+		Hash: hex.EncodeToString(hash[:16]),
+	}
+
+	switch known, ok := s.checkpoint.Known[id]; {
+	case !ok:
+		return Inserted, fingerprint, true, nil
+	case known.Hash != fingerprint.Hash:
+		return Updated, fingerprint, true, nil
+	default:
+		return "", fingerprint, false, nil
+	}
+}
+
+// emit отправляет событие в Sink и обновляет Known после успешной обработки:
+// добавленные и измененные операции запоминаются, удаленные – забываются.
+func (s *syncState) emit(ctx context.Context, event Event, fingerprint Fingerprint) error {
+	if err := s.sink.HandleEvent(ctx, event); err != nil {
+		return s.fail(ctx, errors.Wrapf(err, "handle %s event for %s", event.Type, event.ID))
+	}
+
+	if event.Type == Deleted {
+		delete(s.checkpoint.Known, event.ID)
+	} else {
+		s.checkpoint.Known[event.ID] = fingerprint
+		if fingerprint.Time.After(s.checkpoint.Time) {
+			s.checkpoint.Time = fingerprint.Time
+		}
+	}
+
+	s.pending++
+	if s.pending >= saveEvery {
+		s.pending = 0
+		if err := s.save(ctx, s.interrupted()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// interrupted возвращает состояние, сохраняемое до окончания синхронизации.
+// Время последней синхронизации не изменяется, чтобы повторный запуск проверил тот же период,
+// а уже отправленные события будут отфильтрованы по Known.
+func (s *syncState) interrupted() *Checkpoint {
+	checkpoint := s.checkpoint
+	checkpoint.Time = s.from.Add(s.lookback)
+	checkpoint.Cursor = s.cursor
+	checkpoint.To = time.Time{}
+	if s.cursor != "" {
+		checkpoint.To = s.to
+	}
+
+	return &checkpoint
+}
+
+func (s *syncState) fail(ctx context.Context, err error) error {
+	if saveErr := s.save(ctx, s.interrupted()); saveErr != nil {
+		return errors.Wrapf(err, "also failed to save checkpoint (%s)", saveErr)
+	}
+
+	return err
+}
+
+func (s *syncState) complete(ctx context.Context, startCursor string) error {
+	// deletions can be detected only after the full pass over the lookback window
+	if startCursor == "" {
+		// an interruption from now on restarts the full pass, otherwise the remaining deletions would be skipped
+		s.cursor = ""
+		for id, fingerprint := range s.checkpoint.Known {
+			if s.seen[id] || fingerprint.Time.Before(s.from) || fingerprint.Time.After(s.to) {
+				continue
+			}
+
+			if err := s.emit(ctx, Event{
+				Type:    Deleted,
+				Kind:    s.kind,
+				Account: s.account,
+				ID:      id,
+			}, fingerprint); err != nil {
+				return err
+			}
+		}
+	}
+
+	threshold := s.checkpoint.Time.Add(-s.lookback)
+	for id, fingerprint := range s.checkpoint.Known {
+		if fingerprint.Time.Before(threshold) {
+			delete(s.checkpoint.Known, id)
+		}
+	}
+
+	s.checkpoint.Cursor = ""
+	s.checkpoint.To = time.Time{}
+	return s.save(ctx, &s.checkpoint)
+}
+
+func (s *syncState) save(ctx context.Context, checkpoint *Checkpoint) error {
+	if err := s.checkpointer.UpdateCheckpoint(ctx, s.kind, s.account, checkpoint); err != nil {
+		return errors.Wrap(err, "update checkpoint")
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+package sync_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jfk9w-go/based"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/sync"
+)
+
+type fakeClient struct {
+	operations       []tinkoff.Operation
+	receipts         map[string]*tinkoff.ShoppingReceiptOut
+	receiptRequests  []string
+	investOperations []tinkoff.InvestOperation
+	requests         []tinkoff.InvestOperationsIn
+}
+
+func (c *fakeClient) AccountsLightIb(context.Context) (tinkoff.AccountsLightIbOut, error) {
+	return nil, nil
+}
+
+// OperationsIter возвращает операции счета за период [from, to] в порядке возрастания времени операции.
+func (c *fakeClient) OperationsIter(_ context.Context, account string, from, to time.Time) iter.Seq2[tinkoff.Operation, error] {
+	operations := slices.Clone(c.operations)
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].OperationTime.Time().Before(operations[j].OperationTime.Time())
+	})
+
+	return func(yield func(tinkoff.Operation, error) bool) {
+		for _, operation := range operations {
+			date := operation.OperationTime.Time()
+			if operation.Account != account || date.Before(from) || date.After(to) {
+				continue
+			}
+
+			if !yield(operation, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (c *fakeClient) ShoppingReceipt(_ context.Context, in *tinkoff.ShoppingReceiptIn) (*tinkoff.ShoppingReceiptOut, error) {
+	c.receiptRequests = append(c.receiptRequests, in.OperationId)
+	if receipt, ok := c.receipts[in.OperationId]; ok {
+		return receipt, nil
+	}
+
+	return nil, tinkoff.ErrNoDataFound
+}
+
+func (c *fakeClient) InvestAccounts(context.Context, *tinkoff.InvestAccountsIn) (*tinkoff.InvestAccountsOut, error) {
+	return new(tinkoff.InvestAccountsOut), nil
+}
+
+// InvestOperationsIter возвращает операции в порядке убывания даты, курсор операции равен InternalId.
+func (c *fakeClient) InvestOperationsIter(_ context.Context, in *tinkoff.InvestOperationsIn, _ ...string) iter.Seq2[tinkoff.InvestOperation, error] {
+	c.requests = append(c.requests, *in)
+	operations := slices.Clone(c.investOperations)
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Date.Time().After(operations[j].Date.Time())
+	})
+
+	return func(yield func(tinkoff.InvestOperation, error) bool) {
+		started := in.Cursor == ""
+		for _, operation := range operations {
+			operation.Cursor = operation.InternalId
+			started = started || operation.Cursor == in.Cursor
+			date := operation.Date.Time()
+			if !started || date.Before(in.From) || date.After(in.To) {
+				continue
+			}
+
+			if !yield(operation, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (c *fakeClient) add(id string, date time.Time) {
+	c.investOperations = append(c.investOperations, tinkoff.InvestOperation{
+		BrokerAccountId: "broker",
+		InternalId:      id,
+		Date:            tinkoff.DateTimeMilliOffset(date),
+	})
+}
+
+func (c *fakeClient) addOperation(id, status string, date time.Time, hasReceipt bool) {
+	c.operations = append(c.operations, tinkoff.Operation{
+		Account:            "account",
+		Id:                 id,
+		Status:             status,
+		OperationTime:      tinkoff.Milliseconds(date),
+		HasShoppingReceipt: &hasReceipt,
+	})
+}
+
+func (c *fakeClient) setStatus(id, status string) {
+	for i := range c.operations {
+		if c.operations[i].Id == id {
+			c.operations[i].Status = status
+		}
+	}
+}
+
+func (c *fakeClient) remove(ids ...string) {
+	c.investOperations = slices.DeleteFunc(c.investOperations, func(operation tinkoff.InvestOperation) bool {
+		return slices.Contains(ids, operation.InternalId)
+	})
+
+	c.operations = slices.DeleteFunc(c.operations, func(operation tinkoff.Operation) bool {
+		return slices.Contains(ids, operation.Id)
+	})
+}
+
+type checkpointer map[string]sync.Checkpoint
+
+func (c checkpointer) LoadCheckpoint(_ context.Context, kind sync.Kind, account string) (*sync.Checkpoint, error) {
+	if checkpoint, ok := c[string(kind)+"/"+account]; ok {
+		return &checkpoint, nil
+	}
+
+	return nil, nil
+}
+
+func (c checkpointer) UpdateCheckpoint(_ context.Context, kind sync.Kind, account string, checkpoint *sync.Checkpoint) error {
+	c[string(kind)+"/"+account] = *checkpoint
+	return nil
+}
+
+type sink struct {
+	events []sync.Event
+	failAt int
+}
+
+func (s *sink) HandleEvent(_ context.Context, event sync.Event) error {
+	if s.failAt > 0 && len(s.events)+1 == s.failAt {
+		s.failAt = 0
+		return errors.New("sink failed")
+	}
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *sink) take() map[string]sync.EventType {
+	events := make(map[string]sync.EventType, len(s.events))
+	for _, event := range s.events {
+		events[event.ID] = event.Type
+	}
+
+	s.events = nil
+	return events
+}
+
+func newSyncer(t *testing.T, now *time.Time, client *fakeClient, checkpointer checkpointer, sink *sink) *sync.Syncer {
+	t.Helper()
+	syncer, err := sync.New(sync.Params{
+		Clock:        based.ClockFunc(func() time.Time { return *now }),
+		Client:       client,
+		Checkpointer: checkpointer,
+		Sink:         sink,
+		Receipts:     true,
+	})
+
+	require.NoError(t, err)
+	return syncer
+}
+
+func TestSyncer_InvestOperationsResumesInterruptedPeriod(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	client := new(fakeClient)
+	for i, id := range []string{"a", "b", "c", "d"} {
+		client.add(id, start.Add(-time.Duration(i+1)*time.Hour))
+	}
+
+	checkpoints := make(checkpointer)
+	events := &sink{failAt: 3}
+	syncer := newSyncer(t, &now, client, checkpoints, events)
+
+	require.Error(t, syncer.InvestOperations(ctx, "broker"))
+	assert.Equal(t, map[string]sync.EventType{"a": sync.Inserted, "b": sync.Inserted}, events.take())
+
+	checkpoint := checkpoints[string(sync.InvestOperations)+"/broker"]
+	assert.Equal(t, "c", checkpoint.Cursor)
+	assert.Equal(t, start, checkpoint.To)
+
+	now = start.Add(time.Hour)
+	client.add("new", start.Add(30*time.Minute))
+
+	require.NoError(t, syncer.InvestOperations(ctx, "broker"))
+	assert.Equal(t, map[string]sync.EventType{"c": sync.Inserted, "d": sync.Inserted}, events.take())
+
+	request := client.requests[len(client.requests)-1]
+	assert.Equal(t, "c", request.Cursor)
+	assert.Equal(t, start, request.To)
+
+	checkpoint = checkpoints[string(sync.InvestOperations)+"/broker"]
+	assert.Empty(t, checkpoint.Cursor)
+	assert.True(t, checkpoint.To.IsZero())
+
+	require.NoError(t, syncer.InvestOperations(ctx, "broker"))
+	assert.Equal(t, map[string]sync.EventType{"new": sync.Inserted}, events.take())
+}
+
+func TestSyncer_InvestOperationsCheckpointsDeletions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	client := new(fakeClient)
+	for i, id := range []string{"a", "b", "c"} {
+		client.add(id, now.Add(-time.Duration(i+1)*time.Hour))
+	}
+
+	checkpoints := make(checkpointer)
+	events := new(sink)
+	syncer := newSyncer(t, &now, client, checkpoints, events)
+
+	require.NoError(t, syncer.InvestOperations(ctx, "broker"))
+	assert.Len(t, events.take(), 3)
+
+	client.remove("b", "c")
+	events.failAt = 2
+	require.Error(t, syncer.InvestOperations(ctx, "broker"))
+	deleted := events.take()
+	require.Len(t, deleted, 1)
+
+	// the interrupted pass must be restarted, otherwise the remaining deletion is never detected
+	checkpoint := checkpoints[string(sync.InvestOperations)+"/broker"]
+	assert.Empty(t, checkpoint.Cursor)
+	assert.Len(t, checkpoint.Known, 2)
+
+	require.NoError(t, syncer.InvestOperations(ctx, "broker"))
+	remaining := events.take()
+	require.Len(t, remaining, 1)
+	for id, eventType := range remaining {
+		assert.Equal(t, sync.Deleted, eventType)
+		assert.NotContains(t, deleted, id)
+		assert.Contains(t, []string{"b", "c"}, id)
+	}
+
+	assert.Len(t, checkpoints[string(sync.InvestOperations)+"/broker"].Known, 1)
+}
+
+func TestSyncer_Operations(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	client := &fakeClient{receipts: map[string]*tinkoff.ShoppingReceiptOut{
+		"a": {OperationId: "a"},
+	}}
+
+	client.addOperation("a", "OK", now.Add(-3*time.Hour), true)
+	client.addOperation("b", "AUTHORIZATION", now.Add(-2*time.Hour), false)
+	client.addOperation("c", "OK", now.Add(-time.Hour), true)
+
+	checkpoints := make(checkpointer)
+	events := new(sink)
+	syncer := newSyncer(t, &now, client, checkpoints, events)
+
+	require.NoError(t, syncer.Operations(ctx, "account"))
+	require.Len(t, events.events, 3)
+	for _, event := range events.events {
+		assert.Equal(t, sync.Inserted, event.Type)
+		assert.Equal(t, sync.Operations, event.Kind)
+		assert.Equal(t, event.ID, event.Operation.Id)
+		if event.ID == "a" {
+			assert.Equal(t, &tinkoff.ShoppingReceiptOut{OperationId: "a"}, event.Receipt)
+		} else {
+			assert.Nil(t, event.Receipt)
+		}
+	}
+
+	// receipts are requested only for operations that have them, a missing receipt is not an error
+	assert.Equal(t, []string{"a", "c"}, client.receiptRequests)
+	events.take()
+
+	t.Run("status change inside lookback", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		client.setStatus("b", "OK")
+
+		require.NoError(t, syncer.Operations(ctx, "account"))
+		require.Len(t, events.events, 1)
+		event := events.events[0]
+		assert.Equal(t, sync.Updated, event.Type)
+		assert.Equal(t, "b", event.ID)
+		assert.Equal(t, "OK", event.Operation.Status)
+		events.take()
+	})
+
+	t.Run("deletion on full pass", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		client.remove("a")
+
+		require.NoError(t, syncer.Operations(ctx, "account"))
+		assert.Equal(t, map[string]sync.EventType{"a": sync.Deleted}, events.take())
+		assert.NotContains(t, checkpoints[string(sync.Operations)+"/account"].Known, "a")
+
+		require.NoError(t, syncer.Operations(ctx, "account"))
+		assert.Empty(t, events.take())
+	})
+}
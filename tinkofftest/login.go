@@ -0,0 +1,82 @@
+package tinkofftest
+
+import "net/http"
+
+// loginPage – упрощенная копия страницы входа, достаточная для проверки авторизации через браузер
+// (tinkoff.SeleniumAuthFlow, tinkoff.CDPAuthFlow). Разметка использует те же automation-id, что и настоящая страница.
+const loginPage = `<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Вход</title></head>
+<body>
+<form id="phone-form">
+	<input automation-id="phone-input" name="phone" type="tel">
+</form>
+<form id="password-form" hidden>
+	<input automation-id="password-input" name="password" type="password">
+</form>
+<div id="otp" hidden>
+	<input automation-id="otp-input" name="code" maxlength="4">
+</div>
+<div id="error" hidden></div>
+<a id="done" href="/new-product/" hidden>Продолжить</a>
+<script>
+	const el = id => document.getElementById(id);
+	const step = (hide, show) => { el(hide).hidden = true; el(show).hidden = false; };
+	const phone = document.querySelector("[automation-id=phone-input]");
+	const password = document.querySelector("[automation-id=password-input]");
+	const code = document.querySelector("[automation-id=otp-input]");
+
+	el("phone-form").addEventListener("submit", e => { e.preventDefault(); step("phone-form", "password-form"); });
+	el("password-form").addEventListener("submit", e => { e.preventDefault(); step("password-form", "otp"); });
+	code.addEventListener("input", () => {
+		if (code.value.length < 4) return;
+		fetch("/login/submit", {
+			method: "POST",
+			body: new URLSearchParams({phone: phone.value, password: password.value, code: code.value}),
+		}).then(async resp => {
+			if (resp.ok) {
+				step("otp", "done");
+			} else {
+				el("error").textContent = await resp.text();
+				step("otp", "error");
+			}
+		});
+	});
+</script>
+</body>
+</html>
+`
+
+func (s *Server) login(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(loginPage))
+}
+
+// loginSubmit проверяет данные со страницы входа и устанавливает cookie psid с идентификатором сессии.
+func (s *Server) loginSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[r.URL.Path]++
+
+	switch {
+	case r.PostForm.Get("phone") != s.Phone:
+		http.Error(w, "Неверный номер телефона", http.StatusUnauthorized)
+		return
+	case r.PostForm.Get("password") != s.Password:
+		http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+		return
+	case r.PostForm.Get("code") != s.Code:
+		http.Error(w, "Неверный код подтверждения", http.StatusUnauthorized)
+		return
+	}
+
+	id := s.nextID("session")
+	s.sessions[id] = &session{level: client, confirmed: true}
+	http.SetCookie(w, &http.Cookie{Name: "psid", Value: id, Path: "/", HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
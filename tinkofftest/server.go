@@ -0,0 +1,550 @@
+// Package tinkofftest предоставляет локальный фейковый сервер Т-Банка для интеграционных тестов.
+// Сервер эмулирует часть эндпоинтов /common/v1/* и /invest-gw/*, хранит данные в памяти и позволяет
+// задавать коды ответов для отдельных вызовов или задерживать ответы.
+package tinkofftest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+const (
+	anonymous  = "ANONYMOUS"
+	registered = "REGISTERED"
+	client     = "CLIENT"
+
+	investTimeLayout       = "2006-01-02T15:04:05.999Z"
+	defaultInvestPageLimit = 50
+)
+
+// Server – фейковый сервер Т-Банка.
+// Адрес сервера нужно передать в tinkoff.ClientParams.BaseURL.
+type Server struct {
+	*httptest.Server
+
+	// Phone, Password и Code используются при проверке авторизации через tinkoff.ApiAuthFlow
+	// и через страницу входа /login (tinkoff.SeleniumAuthFlow, tinkoff.CDPAuthFlow).
+	Phone    string
+	Password string
+	Code     string
+
+	sessions             map[string]*session
+	tickets              map[string]string
+	accounts             []tinkoff.Account
+	operations           map[string][]tinkoff.Operation
+	receipts             map[string]tinkoff.ShoppingReceiptOut
+	investOperationTypes []tinkoff.InvestOperationType
+	investAccounts       []tinkoff.InvestAccount
+	investOperations     []tinkoff.InvestOperation
+	scripts              map[string][]string
+	holds                map[string]*hold
+	requests             map[string]int
+	seq                  int
+	mu                   sync.Mutex
+}
+
+type hold struct {
+	held    chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+type session struct {
+	level     string
+	confirmed bool
+}
+
+// NewServer создает и запускает фейковый сервер.
+// Сервер должен быть остановлен вызовом Close.
+func NewServer() *Server {
+	s := &Server{
+		Phone:      "+79999999999",
+		Password:   "password",
+		Code:       "1234",
+		sessions:   make(map[string]*session),
+		tickets:    make(map[string]string),
+		operations: make(map[string][]tinkoff.Operation),
+		receipts:   make(map[string]tinkoff.ShoppingReceiptOut),
+		scripts:    make(map[string][]string),
+		holds:      make(map[string]*hold),
+		requests:   make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", s.login)
+	mux.HandleFunc("/login/submit", s.loginSubmit)
+	mux.HandleFunc("/api/common/v1/session", s.common(s.createSession, false))
+	mux.HandleFunc("/api/common/v1/ping", s.common(s.ping, false))
+	mux.HandleFunc("/api/common/v1/sign_up", s.common(s.signUp, false))
+	mux.HandleFunc("/api/common/v1/confirm", s.common(s.confirm, false))
+	mux.HandleFunc("/api/common/v1/level_up", s.common(s.levelUp, false))
+	mux.HandleFunc("/api/common/v1/accounts_light_ib", s.common(s.accountsLightIb, true))
+	mux.HandleFunc("/api/common/v1/operations", s.common(s.operationsList, true))
+	mux.HandleFunc("/api/common/v1/shopping_receipt", s.common(s.shoppingReceipt, true))
+	mux.HandleFunc("/api/invest-gw/ca-operations/api/v1/operations/types", s.invest(s.investTypes, false))
+	mux.HandleFunc("/api/invest-gw/invest-portfolio/portfolios/accounts", s.invest(s.investAccountsList, true))
+	mux.HandleFunc("/api/invest-gw/ca-operations/api/v1/user/operations", s.invest(s.investOperationsList, true))
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// NewSession регистрирует на сервере уже авторизованную сессию.
+// Это позволяет сохранить ее в tinkoff.SessionStorage и пропустить авторизацию в тестах.
+func (s *Server) NewSession() *tinkoff.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID("session")
+	s.sessions[id] = &session{level: client}
+	return &tinkoff.Session{ID: id}
+}
+
+// ExpireSession делает сессию недействительной, как это происходит на реальном сервере по истечении времени.
+func (s *Server) ExpireSession(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// Script задает коды ответа для последующих вызовов эндпоинта path (например, "/common/v1/operations").
+// Каждый вызов использует один код из очереди; пустая строка означает обычную обработку запроса.
+// Для эндпоинтов /invest-gw/* код возвращается в поле errorCode ответа с ошибкой.
+func (s *Server) Script(path string, codes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[path] = append(s.scripts[path], codes...)
+}
+
+// Hold задерживает ответы эндпоинта path до вызова release или отмены запроса клиентом.
+// Канал held получает значение каждый раз, когда очередной запрос начинает ожидание.
+// Запросы, отмененные во время ожидания, не учитываются в Requests.
+func (s *Server) Hold(path string) (held <-chan struct{}, release func()) {
+	h := &hold{
+		held:    make(chan struct{}, 16),
+		release: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holds[path] = h
+	return h.held, func() {
+		h.once.Do(func() { close(h.release) })
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.holds[path] == h {
+			delete(s.holds, path)
+		}
+	}
+}
+
+// wait ожидает освобождения эндпоинта, задержанного через Hold.
+// Возвращает false, если клиент отменил запрос.
+func (s *Server) wait(r *http.Request, path string) bool {
+	s.mu.Lock()
+	h := s.holds[path]
+	s.mu.Unlock()
+	if h == nil {
+		return true
+	}
+
+	select {
+	case h.held <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-h.release:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// Requests возвращает количество запросов, полученных эндпоинтом path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) AddAccounts(accounts ...tinkoff.Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts = append(s.accounts, accounts...)
+}
+
+func (s *Server) AddOperations(account string, operations ...tinkoff.Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations[account] = append(s.operations[account], operations...)
+}
+
+func (s *Server) AddReceipt(receipt tinkoff.ShoppingReceiptOut) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receipts[receipt.OperationId] = receipt
+}
+
+func (s *Server) AddInvestOperationTypes(types ...tinkoff.InvestOperationType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.investOperationTypes = append(s.investOperationTypes, types...)
+}
+
+func (s *Server) AddInvestAccounts(accounts ...tinkoff.InvestAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.investAccounts = append(s.investAccounts, accounts...)
+}
+
+// AddInvestOperations добавляет операции по брокерским счетам.
+// Операции отдаются постранично в порядке убывания даты, поле Cursor заполняется сервером.
+func (s *Server) AddInvestOperations(operations ...tinkoff.InvestOperation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.investOperations = append(s.investOperations, operations...)
+	sort.SliceStable(s.investOperations, func(i, j int) bool {
+		return s.investOperations[i].Date.Time().After(s.investOperations[j].Date.Time())
+	})
+
+	for i := range s.investOperations {
+		s.investOperations[i].Cursor = strconv.Itoa(i)
+	}
+}
+
+// Authorizer возвращает tinkoff.Authorizer, который отвечает кодом подтверждения сервера.
+func (s *Server) Authorizer() tinkoff.Authorizer {
+	return authorizer{server: s}
+}
+
+type commonResponse struct {
+	ResultCode      string `json:"resultCode"`
+	ErrorMessage    string `json:"errorMessage,omitempty"`
+	Payload         any    `json:"payload,omitempty"`
+	OperationTicket string `json:"operationTicket,omitempty"`
+}
+
+type investError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorCode    string `json:"errorCode"`
+}
+
+type commonHandler func(r *http.Request, session *session) commonResponse
+
+func (s *Server) common(handle commonHandler, auth bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		path := r.URL.Path[len("/api"):]
+		if !s.wait(r, path) {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests[path]++
+
+		var resp commonResponse
+		session := s.sessions[r.URL.Query().Get("sessionid")]
+		if code := s.popScript(path); code != "" {
+			resp = commonResponse{ResultCode: code, ErrorMessage: "scripted"}
+		} else if auth && (session == nil || session.level != client) {
+			resp = commonResponse{ResultCode: "INSUFFICIENT_PRIVILEGES", ErrorMessage: "Недостаточно прав"}
+		} else {
+			resp = handle(r, session)
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+type investHandler func(r *http.Request) (any, *investError)
+
+func (s *Server) invest(handle investHandler, auth bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[len("/api"):]
+		if !s.wait(r, path) {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests[path]++
+
+		if code := s.popScript(path); code != "" {
+			writeInvestError(w, &investError{ErrorMessage: "scripted", ErrorCode: code})
+			return
+		}
+
+		if auth {
+			if session := s.sessions[r.URL.Query().Get("sessionId")]; session == nil || session.level != client {
+				writeInvestError(w, &investError{ErrorMessage: "Доступ запрещен", ErrorCode: "Forbidden"})
+				return
+			}
+		}
+
+		resp, err := handle(r)
+		if err != nil {
+			writeInvestError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) popScript(path string) string {
+	codes := s.scripts[path]
+	if len(codes) == 0 {
+		return ""
+	}
+
+	s.scripts[path] = codes[1:]
+	return codes[0]
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	return prefix + "-" + strconv.Itoa(s.seq)
+}
+
+func (s *Server) createSession(_ *http.Request, _ *session) commonResponse {
+	id := s.nextID("session")
+	s.sessions[id] = &session{level: anonymous}
+	return commonResponse{ResultCode: "OK", Payload: id}
+}
+
+func (s *Server) ping(_ *http.Request, session *session) commonResponse {
+	level := anonymous
+	if session != nil {
+		level = session.level
+	}
+
+	return commonResponse{ResultCode: "OK", Payload: map[string]string{"accessLevel": level}}
+}
+
+func (s *Server) signUp(r *http.Request, session *session) commonResponse {
+	if session == nil {
+		return commonResponse{ResultCode: "INSUFFICIENT_PRIVILEGES", ErrorMessage: "Сессия не найдена"}
+	}
+
+	switch {
+	case r.PostForm.Has("phone"):
+		if r.PostForm.Get("phone") != s.Phone {
+			return commonResponse{ResultCode: "INVALID_REQUEST_DATA", ErrorMessage: "Неверный номер телефона"}
+		}
+
+		ticket := s.nextID("ticket")
+		s.tickets[ticket] = r.URL.Query().Get("sessionid")
+		return commonResponse{ResultCode: "WAITING_CONFIRMATION", OperationTicket: ticket}
+
+	case r.PostForm.Has("password"):
+		if !session.confirmed {
+			return commonResponse{ResultCode: "INSUFFICIENT_PRIVILEGES", ErrorMessage: "Телефон не подтвержден"}
+		}
+
+		if r.PostForm.Get("password") != s.Password {
+			return commonResponse{ResultCode: "AUTHENTICATION_FAILED", ErrorMessage: "Неверный пароль"}
+		}
+
+		session.level = registered
+		return commonResponse{ResultCode: "OK"}
+
+	default:
+		return commonResponse{ResultCode: "INVALID_REQUEST_DATA"}
+	}
+}
+
+func (s *Server) confirm(r *http.Request, session *session) commonResponse {
+	sessionID, ok := s.tickets[r.PostForm.Get("initialOperationTicket")]
+	if !ok || session == nil || sessionID != r.URL.Query().Get("sessionid") {
+		return commonResponse{ResultCode: "INVALID_REQUEST_DATA", ErrorMessage: "Неверный тикет операции"}
+	}
+
+	var data struct {
+		SMSBYID string `json:"SMSBYID"`
+	}
+
+	if err := json.Unmarshal([]byte(r.PostForm.Get("confirmationData")), &data); err != nil || data.SMSBYID != s.Code {
+		return commonResponse{ResultCode: "CONFIRMATION_FAILED", ErrorMessage: "Неверный код подтверждения"}
+	}
+
+	delete(s.tickets, r.PostForm.Get("initialOperationTicket"))
+	session.confirmed = true
+	return commonResponse{ResultCode: "OK"}
+}
+
+func (s *Server) levelUp(_ *http.Request, session *session) commonResponse {
+	if session == nil || session.level != registered {
+		return commonResponse{ResultCode: "INSUFFICIENT_PRIVILEGES"}
+	}
+
+	session.level = client
+	return commonResponse{ResultCode: "OK"}
+}
+
+func (s *Server) accountsLightIb(_ *http.Request, _ *session) commonResponse {
+	accounts := s.accounts
+	if accounts == nil {
+		accounts = []tinkoff.Account{}
+	}
+
+	return commonResponse{ResultCode: "OK", Payload: accounts}
+}
+
+func (s *Server) operationsList(r *http.Request, _ *session) commonResponse {
+	start, err := parseMillis(r.PostForm.Get("start"))
+	if err != nil {
+		return commonResponse{ResultCode: "INVALID_REQUEST_DATA", ErrorMessage: "Неверный параметр start"}
+	}
+
+	end := time.Now()
+	if r.PostForm.Has("end") {
+		if end, err = parseMillis(r.PostForm.Get("end")); err != nil {
+			return commonResponse{ResultCode: "INVALID_REQUEST_DATA", ErrorMessage: "Неверный параметр end"}
+		}
+	}
+
+	operations := make([]tinkoff.Operation, 0)
+	for _, operation := range s.operations[r.PostForm.Get("account")] {
+		operationTime := operation.OperationTime.Time()
+		if !operationTime.Before(start) && !operationTime.After(end) {
+			operations = append(operations, operation)
+		}
+	}
+
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].OperationTime.Time().After(operations[j].OperationTime.Time())
+	})
+
+	return commonResponse{ResultCode: "OK", Payload: operations}
+}
+
+func (s *Server) shoppingReceipt(r *http.Request, _ *session) commonResponse {
+	receipt, ok := s.receipts[r.PostForm.Get("operationId")]
+	if !ok {
+		return commonResponse{ResultCode: "NO_DATA_FOUND", ErrorMessage: "Чек не найден"}
+	}
+
+	return commonResponse{ResultCode: "OK", Payload: receipt}
+}
+
+func (s *Server) investTypes(_ *http.Request) (any, *investError) {
+	types := s.investOperationTypes
+	if types == nil {
+		types = []tinkoff.InvestOperationType{}
+	}
+
+	return tinkoff.InvestOperationTypesOut{OperationsTypes: types}, nil
+}
+
+func (s *Server) investAccountsList(_ *http.Request) (any, *investError) {
+	accounts := s.investAccounts
+	if accounts == nil {
+		accounts = []tinkoff.InvestAccount{}
+	}
+
+	return tinkoff.InvestAccountsOut{
+		Accounts: tinkoff.InvestAccounts{
+			Count: len(accounts),
+			List:  accounts,
+		},
+	}, nil
+}
+
+func (s *Server) investOperationsList(r *http.Request) (any, *investError) {
+	query := r.URL.Query()
+
+	var from, to time.Time
+	if value := query.Get("from"); value != "" {
+		var err error
+		if from, err = time.Parse(investTimeLayout, value); err != nil {
+			return nil, &investError{ErrorMessage: "invalid from", ErrorCode: "InvalidRequest"}
+		}
+	}
+
+	if value := query.Get("to"); value != "" {
+		var err error
+		if to, err = time.Parse(investTimeLayout, value); err != nil {
+			return nil, &investError{ErrorMessage: "invalid to", ErrorCode: "InvalidRequest"}
+		}
+	}
+
+	limit := defaultInvestPageLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return nil, &investError{ErrorMessage: "invalid limit", ErrorCode: "InvalidRequest"}
+		}
+	}
+
+	offset := 0
+	if value := query.Get("cursor"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return nil, &investError{ErrorMessage: "invalid cursor", ErrorCode: "InvalidRequest"}
+		}
+	}
+
+	brokerAccountID := query.Get("brokerAccountId")
+	out := tinkoff.InvestOperationsOut{Items: make([]tinkoff.InvestOperation, 0)}
+	for i := offset; i < len(s.investOperations); i++ {
+		operation := s.investOperations[i]
+		date := operation.Date.Time()
+		switch {
+		case brokerAccountID != "" && operation.BrokerAccountId != brokerAccountID:
+			continue
+		case !from.IsZero() && date.Before(from):
+			continue
+		case !to.IsZero() && date.After(to):
+			continue
+		}
+
+		if len(out.Items) == limit {
+			out.HasNext = true
+			out.NextCursor = operation.Cursor
+			break
+		}
+
+		out.Items = append(out.Items, operation)
+	}
+
+	return out, nil
+}
+
+func parseMillis(value string) (time.Time, error) {
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.UnixMilli(millis), nil
+}
+
+func writeInvestError(w http.ResponseWriter, err *investError) {
+	status := http.StatusBadRequest
+	switch err.ErrorCode {
+	case "Forbidden":
+		status = http.StatusForbidden
+	case "404":
+		status = http.StatusNotFound
+	}
+
+	writeJSON(w, status, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
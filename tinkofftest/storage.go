@@ -0,0 +1,48 @@
+package tinkofftest
+
+import (
+	"context"
+	"sync"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+)
+
+type authorizer struct {
+	server *Server
+}
+
+func (a authorizer) GetConfirmationCode(_ context.Context, _ string) (string, error) {
+	return a.server.Code, nil
+}
+
+// SessionStorage хранит сессии в памяти.
+type SessionStorage struct {
+	sessions map[string]tinkoff.Session
+	mu       sync.Mutex
+}
+
+func (s *SessionStorage) LoadSession(_ context.Context, phone string) (*tinkoff.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[phone]; ok {
+		return &session, nil
+	}
+
+	return nil, nil
+}
+
+func (s *SessionStorage) UpdateSession(_ context.Context, phone string, session *tinkoff.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]tinkoff.Session)
+	}
+
+	if session != nil {
+		s.sessions[phone] = *session
+	} else {
+		delete(s.sessions, phone)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package tinkoff
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/jfk9w-go/tbank-api"
+
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = noop.NewTracerProvider()
+	}
+
+	return provider.Tracer(tracerName)
+}
+
+// startExchangeSpan начинает span для запроса к API, включая все повторы.
+// Код результата, HTTP-статус и количество повторов последней попытки добавляются в span в observeExchange.
+func (c *Client) startExchangeSpan(ctx context.Context, path string, auth AuthMode) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("tbank.path", path),
+			attribute.String("tbank.auth", auth.String()),
+		))
+}
+
+// startChildSpan начинает вложенный span. Возвращаемая функция завершает его с ошибкой err.
+func (c *Client) startChildSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	spanCtx, span := c.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return spanCtx, func(err error) { endSpan(span, err) }
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// detachSpan возвращает ctx с текущим span из parent.
+// Используется для контекстов, полученных при ожидании блокировок внутри вложенного span,
+// чтобы этот span не становился родительским для последующих.
+func detachSpan(ctx, parent context.Context) context.Context {
+	return trace.ContextWithSpan(ctx, trace.SpanFromContext(parent))
+}
@@ -0,0 +1,138 @@
+package tinkoff_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	tinkoff "github.com/jfk9w-go/tbank-api"
+	"github.com/jfk9w-go/tbank-api/tinkofftest"
+)
+
+type recordedSpans []sdktrace.ReadOnlySpan
+
+func (s recordedSpans) find(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, span := range s {
+		if span.Name() == name {
+			return span
+		}
+	}
+
+	require.Failf(t, "span not found", "%s", name)
+	return nil
+}
+
+// child возвращает вложенный span parent с именем name.
+func (s recordedSpans) child(t *testing.T, parent sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, span := range s {
+		if span.Name() == name && span.Parent().SpanID() == parent.SpanContext().SpanID() {
+			return span
+		}
+	}
+
+	require.Failf(t, "span not found", "%s in %s", name, parent.Name())
+	return nil
+}
+
+func (s recordedSpans) children(parent sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, span := range s {
+		if span.Parent().SpanID() == parent.SpanContext().SpanID() {
+			names = append(names, span.Name())
+		}
+	}
+
+	return names
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+
+	return attrs
+}
+
+func TestClient_Tracing(t *testing.T) {
+	server := tinkofftest.NewServer()
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	ctx := testContext(t, server)
+	client := newTestClient(t, server, tinkoff.ClientParams{TracerProvider: provider})
+
+	_, err := client.AccountsLightIb(ctx)
+	require.NoError(t, err)
+
+	spans := recordedSpans(recorder.Ended())
+	exchange := spans.find(t, "/common/v1/accounts_light_ib")
+	assert.False(t, exchange.Parent().IsValid())
+	assert.Equal(t, trace.SpanKindClient, exchange.SpanKind())
+	assert.Equal(t, codes.Unset, exchange.Status().Code)
+	attrs := spanAttributes(exchange)
+	assert.Equal(t, int64(200), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "OK", attrs["tbank.result_code"].AsString())
+	assert.Equal(t, "/common/v1/accounts_light_ib", attrs["tbank.path"].AsString())
+	assert.Equal(t, "force", attrs["tbank.auth"].AsString())
+	assert.Equal(t, []string{"tbank.rate_limit.wait", "tbank.session.acquire"}, spans.children(exchange))
+
+	// authorization happens while the session is acquired, and its requests are traced under it
+	acquire := spans.child(t, exchange, "tbank.session.acquire")
+	assert.Equal(t, []string{"tbank.authorize"}, spans.children(acquire))
+	authorize := spans.child(t, acquire, "tbank.authorize")
+	assert.Equal(t, "api", spanAttributes(authorize)["tbank.auth_flow"].AsString())
+	assert.Contains(t, spans.children(authorize), "/common/v1/session")
+	assert.Equal(t, "none", spanAttributes(spans.child(t, authorize, "/common/v1/session"))["tbank.auth"].AsString())
+
+	t.Run("reauthorize", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider.RegisterSpanProcessor(recorder)
+		defer provider.UnregisterSpanProcessor(recorder)
+
+		server.Script("/common/v1/accounts_light_ib", tinkoff.ResultCodeInsufficientPrivileges)
+		_, err := client.AccountsLightIb(ctx)
+		require.NoError(t, err)
+
+		spans := recordedSpans(recorder.Ended())
+		exchange := spans.find(t, "/common/v1/accounts_light_ib")
+		assert.Equal(t, []string{
+			"tbank.rate_limit.wait",
+			"tbank.session.acquire",
+			"tbank.reauthorize",
+			"tbank.session.acquire",
+		}, spans.children(exchange))
+
+		reauthorize := spans.child(t, exchange, "tbank.reauthorize")
+		assert.Equal(t, tinkoff.ResultCodeInsufficientPrivileges, spanAttributes(reauthorize)["tbank.reason"].AsString())
+		assert.Equal(t, []string{"tbank.authorize"}, spans.children(reauthorize))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider.RegisterSpanProcessor(recorder)
+		defer provider.UnregisterSpanProcessor(recorder)
+
+		server.Script("/common/v1/accounts_light_ib", "INTERNAL_ERROR")
+		_, err := client.AccountsLightIb(ctx)
+		require.Error(t, err)
+
+		exchange := recordedSpans(recorder.Ended()).find(t, "/common/v1/accounts_light_ib")
+		assert.Equal(t, codes.Error, exchange.Status().Code)
+		assert.Equal(t, "INTERNAL_ERROR", spanAttributes(exchange)["tbank.result_code"].AsString())
+	})
+}